@@ -0,0 +1,634 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kaatinga/robot/internal/job"
+	"github.com/kaatinga/robot/internal/pretty"
+	"github.com/kaatinga/robot/internal/tool"
+)
+
+type command struct {
+	name        string
+	description string
+	// setup registers the command flags and returns the function that runs the command.
+	setup func(flags *flag.FlagSet) func(ctx context.Context) error
+}
+
+var commands = append(jobCommands(),
+	command{
+		name:        "run",
+		description: "Run a pipeline of jobs in every Go repository in a single pass",
+		setup:       setupRun,
+	},
+	command{
+		name:        "templates list",
+		description: "List the effective templates of every template set and where they come from",
+		setup:       setupListTemplates,
+	},
+)
+
+// jobDefinition is a job that runs in every selected Go repository, either as a command of its own
+// or as a step of the pipeline of the run command.
+type jobDefinition struct {
+	name        string
+	description string
+	// pullRequests reports that the job changes files through pull requests, taking the pull request flags.
+	pullRequests bool
+	// dryRun reports that the job takes -dry-run, printing the changes it plans instead of making them.
+	dryRun bool
+	// setup registers the flags of the job and returns the function that creates the job.
+	// The shared flags are registered by the command.
+	setup func(flags *flag.FlagSet, shared *sharedFlags) func(ctx context.Context) (job.Job, error)
+}
+
+// jobs is the registry of the jobs by name.
+var jobs = []jobDefinition{
+	{
+		name:         "update-workflows",
+		description:  "Synchronise the workflow files of every Go repository with the templates",
+		pullRequests: true,
+		dryRun:       true,
+		setup:        setupUpdateWorkflows,
+	},
+	{
+		name:         "badges",
+		description:  "Insert or refresh the badges of the managed workflows in the README of every Go repository",
+		pullRequests: true,
+		dryRun:       true,
+		setup:        setupBadges,
+	},
+	{
+		name:        "cleanup-branches",
+		description: "Delete the branches left behind by the robot",
+		dryRun:      true,
+		setup:       setupCleanupBranches,
+	},
+	{
+		name:        "list-repos",
+		description: "List the Go repositories the robot would process",
+		setup:       setupListRepos,
+	},
+}
+
+// jobCommands returns a command running each of the jobs on its own.
+func jobCommands() []command {
+	jobCommands := make([]command, len(jobs))
+	for i, definition := range jobs {
+		definition := definition
+		jobCommands[i] = command{
+			name:        definition.name,
+			description: definition.description,
+			setup: func(flags *flag.FlagSet) func(ctx context.Context) error {
+				var scan scanFlags
+				scan.register(flags)
+				var shared sharedFlags
+				shared.register(flags, definition)
+				newJob := definition.setup(flags, &shared)
+
+				return func(ctx context.Context) error {
+					scanOptions, err := scan.options()
+					if err != nil {
+						return err
+					}
+
+					j, err := newJob(ctx)
+					if err != nil {
+						return err
+					}
+					shared.announce()
+
+					return job.FetchAllGoRepos(ctx, j, scanOptions)
+				}
+			},
+		}
+	}
+
+	return jobCommands
+}
+
+// jobNames returns the names of the registered jobs.
+func jobNames() []string {
+	names := make([]string, len(jobs))
+	for i, definition := range jobs {
+		names[i] = definition.name
+	}
+
+	return names
+}
+
+func setupRun(flags *flag.FlagSet) func(ctx context.Context) error {
+	var scan scanFlags
+	scan.register(flags)
+	var names stringList
+	flags.Var(&names, "job", "job run in every repository, in the order given; can be repeated: "+strings.Join(jobNames(), ", "))
+	sharePullRequest := flags.Bool("share-pull-request", false, "commit the changes of all the jobs changing files to the pull request of update-workflows")
+
+	// the flags of all the jobs are accepted, as any of them can be in the pipeline
+	var shared sharedFlags
+	shared.register(flags, jobs...)
+	newJobs := make(map[string]func(ctx context.Context) (job.Job, error), len(jobs))
+	for _, definition := range jobs {
+		newJobs[definition.name] = definition.setup(flags, &shared)
+	}
+
+	return func(ctx context.Context) error {
+		config := tool.GetConfig()
+
+		scanOptions, err := scan.options()
+		if err != nil {
+			return err
+		}
+
+		names := listOption(flags, "job", names, config.Pipeline.Jobs)
+		if len(names) == 0 {
+			return fmt.Errorf("no jobs to run: give them with -job or pipeline.jobs in the configuration file")
+		}
+
+		pipelineJobs := make([]job.Job, 0, len(names))
+		for i, name := range names {
+			newJob, found := newJobs[name]
+			switch {
+			case !found:
+				return fmt.Errorf("unknown job '%s': must be one of %s", name, strings.Join(jobNames(), ", "))
+			case slices.Contains(names[:i], name):
+				return fmt.Errorf("job '%s' is given more than once", name)
+			}
+
+			j, err := newJob(ctx)
+			if err != nil {
+				return err
+			}
+			pipelineJobs = append(pipelineJobs, j)
+		}
+
+		pipeline, err := job.NewPipeline(pipelineJobs, option(flags, "share-pull-request", *sharePullRequest, config.Pipeline.SharePullRequest))
+		if err != nil {
+			return err
+		}
+		shared.announce()
+
+		return job.FetchAllGoRepos(ctx, pipeline, scanOptions)
+	}
+}
+
+// findCommand returns the command named by the first arguments and the arguments following the name.
+func findCommand(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && slices.Equal(args[:len(words)], words) {
+			return cmd, args[len(words):], true
+		}
+	}
+
+	return command{}, nil, false
+}
+
+// isSet reports whether the flag was given on the command line.
+func isSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+
+	return set
+}
+
+// option returns the value of the flag if it was given on the command line or if the configured value is empty,
+// and the configured value otherwise.
+func option[T comparable](flags *flag.FlagSet, name string, flagValue, configValue T) T {
+	var empty T
+	if isSet(flags, name) || configValue == empty {
+		return flagValue
+	}
+
+	return configValue
+}
+
+// listOption is option for lists.
+func listOption(flags *flag.FlagSet, name string, flagValue, configValue []string) []string {
+	if isSet(flags, name) || len(configValue) == 0 {
+		return flagValue
+	}
+
+	return configValue
+}
+
+// stringList is a flag value that can be repeated or given as a comma-separated list.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+
+	return nil
+}
+
+// mapFlag is a flag value collecting key=value pairs. It can be repeated or given as a comma-separated list.
+type mapFlag map[string]string
+
+func (m *mapFlag) String() string {
+	pairs := make([]string, 0, len(*m))
+	for key, value := range *m {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func (m *mapFlag) Set(value string) error {
+	if *m == nil {
+		*m = make(mapFlag)
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		key, value, found := strings.Cut(pair, "=")
+		if key, value = strings.TrimSpace(key), strings.TrimSpace(value); !found || key == "" || value == "" {
+			return fmt.Errorf("'%s' is not a key=value pair", pair)
+		}
+		(*m)[key] = value
+	}
+
+	return nil
+}
+
+// scanFlags holds the flags shared by all the commands that walk over repositories.
+type scanFlags struct {
+	owners      stringList
+	include     stringList
+	exclude     stringList
+	match       regexpFlag
+	topics      stringList
+	visibility  string
+	pushedAfter sinceFlag
+	languages   stringList
+	deny        stringList
+	workers     int
+	keepGoing   bool
+	flags       *flag.FlagSet
+}
+
+func (f *scanFlags) register(flags *flag.FlagSet) {
+	f.flags = flags
+	flags.Var(&f.owners, "owner", "user or organization owning the repositories; can be repeated; defaults to the user the token belongs to")
+	flags.Var(&f.include, "repo", "process only repositories with names matching the glob pattern; can be repeated")
+	flags.Var(&f.exclude, "exclude", "skip repositories with names matching the glob pattern; can be repeated")
+	flags.Var(&f.match, "match", "process only repositories with names matching the regular expression")
+	flags.Var(&f.topics, "topic", "process only repositories with the topic; can be repeated")
+	flags.StringVar(&f.visibility, "visibility", "", "process only 'public' or 'private' repositories")
+	flags.Var(&f.pushedAfter, "pushed-after", "process only repositories pushed after the date (2006-01-02) or within the duration (e.g. 720h)")
+	flags.Var(&f.languages, "language", "process only repositories with the primary language; can be repeated")
+	flags.Var(&f.deny, "deny", "never process the repository given by name or owner/name; can be repeated")
+	flags.IntVar(&f.workers, "workers", 1, "number of repositories processed concurrently")
+	flags.BoolVar(&f.keepGoing, "continue-on-error", false, "keep processing the remaining repositories when a repository fails")
+}
+
+// options returns the scan options given by the flags, falling back to the configuration file.
+func (f *scanFlags) options() (job.ScanOptions, error) {
+	config := tool.GetConfig()
+
+	match := f.match.Regexp
+	if !isSet(f.flags, "match") && config.Filter.Match != "" {
+		var err error
+		if match, err = regexp.Compile(config.Filter.Match); err != nil {
+			return job.ScanOptions{}, err
+		}
+	}
+
+	pushedAfter := f.pushedAfter.Time
+	if !isSet(f.flags, "pushed-after") && config.Filter.PushedAfter != "" {
+		var err error
+		if pushedAfter, err = tool.ParseSince(config.Filter.PushedAfter); err != nil {
+			return job.ScanOptions{}, err
+		}
+	}
+
+	// the repositories denied or skipped in the configuration are never processed, whatever the flags are
+	deny := append(append([]string(nil), config.Filter.Deny...), f.deny...)
+	for _, name := range sortedNames(config.Repositories) {
+		if config.Repositories[name].Skip {
+			deny = append(deny, name)
+		}
+	}
+
+	return job.ScanOptions{
+		Owners: listOption(f.flags, "owner", f.owners, config.Owners),
+		Filter: job.Filter{
+			Include:     listOption(f.flags, "repo", f.include, config.Filter.Include),
+			Exclude:     listOption(f.flags, "exclude", f.exclude, config.Filter.Exclude),
+			Match:       match,
+			Topics:      listOption(f.flags, "topic", f.topics, config.Filter.Topics),
+			Visibility:  option(f.flags, "visibility", f.visibility, config.Filter.Visibility),
+			PushedAfter: pushedAfter,
+			Languages:   listOption(f.flags, "language", f.languages, config.Filter.Languages),
+			Deny:        deny,
+		},
+		Workers:         option(f.flags, "workers", f.workers, tool.GetOptions().Workers),
+		ContinueOnError: option(f.flags, "continue-on-error", f.keepGoing, config.ContinueOnError),
+	}, nil
+}
+
+// sortedNames returns the keys of the map in ascending order.
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// templateFlags holds the flags selecting the templates.
+type templateFlags struct {
+	dir        string
+	noEmbedded bool
+	flags      *flag.FlagSet
+}
+
+func (f *templateFlags) register(flags *flag.FlagSet) {
+	f.flags = flags
+	flags.StringVar(&f.dir, "templates", "", "directory with templates replacing or extending the embedded ones")
+	flags.BoolVar(&f.noEmbedded, "no-embedded-templates", false, "use only the templates in the -templates directory")
+}
+
+// options returns the template options given by the flags, falling back to the configuration file.
+func (f *templateFlags) options() job.TemplateOptions {
+	config := tool.GetConfig()
+
+	sets := make([]job.TemplateSet, 0, len(config.TemplateSets))
+	for _, set := range config.TemplateSets {
+		sets = append(sets, job.TemplateSet{
+			Name:    set.Name,
+			Dir:     set.Dir,
+			Extends: set.Extends,
+			Repos:   set.Repos,
+			Topics:  set.Topics,
+		})
+	}
+
+	return job.TemplateOptions{
+		Dir:        option(f.flags, "templates", f.dir, config.Templates),
+		NoEmbedded: option(f.flags, "no-embedded-templates", f.noEmbedded, config.NoEmbeddedTemplates),
+		Sets:       sets,
+	}
+}
+
+// regexpFlag is a flag value holding a compiled regular expression.
+type regexpFlag struct {
+	*regexp.Regexp
+}
+
+func (f *regexpFlag) String() string {
+	if f.Regexp == nil {
+		return ""
+	}
+
+	return f.Regexp.String()
+}
+
+func (f *regexpFlag) Set(value string) (err error) {
+	f.Regexp, err = regexp.Compile(value)
+	return err
+}
+
+// sinceFlag is a flag value holding a point in time given as a date or as a duration back from now.
+type sinceFlag struct {
+	time.Time
+}
+
+func (f *sinceFlag) String() string {
+	if f.Time.IsZero() {
+		return ""
+	}
+
+	return f.Time.Format(time.DateOnly)
+}
+
+func (f *sinceFlag) Set(value string) (err error) {
+	f.Time, err = tool.ParseSince(value)
+	return err
+}
+
+func setupUpdateWorkflows(flags *flag.FlagSet, shared *sharedFlags) func(ctx context.Context) (job.Job, error) {
+	var templates templateFlags
+	templates.register(flags)
+	var values mapFlag
+	flags.Var(&values, "var", "name=value pair available in the templates as [[ .Values.name ]]; can be repeated")
+	latestGo := flags.String("latest-go", "", "latest stable Go version the version matrix ends with; fetched from go.dev if empty")
+	var keep stringList
+	flags.Var(&keep, "keep", "glob pattern of the files never created, updated or deleted, matching the path or the name; can be repeated")
+
+	return func(ctx context.Context) (job.Job, error) {
+		printer := pretty.NewScopePrinter("")
+		config := tool.GetConfig()
+
+		*latestGo = option(flags, "latest-go", *latestGo, config.LatestGo)
+		if *latestGo == "" {
+			version, err := job.LatestGoVersion(ctx)
+			if err != nil {
+				printer.Error("The latest Go version is unknown, the version matrix ends with the version in go.mod: %v", err)
+			}
+			*latestGo = version
+		}
+
+		options := shared.pullRequestOptions()
+		options.Templates = templates.options()
+		options.Keep = listOption(flags, "keep", keep, config.Keep)
+		options.Values = mergeMaps(config.Values, values)
+		options.LatestGoVersion = *latestGo
+
+		return job.NewUpdateWorkflowJob(options)
+	}
+}
+
+func setupBadges(_ *flag.FlagSet, shared *sharedFlags) func(ctx context.Context) (job.Job, error) {
+	return func(context.Context) (job.Job, error) {
+		return job.NewBadgesJob(shared.pullRequestOptions())
+	}
+}
+
+// sharedFlags holds the flags taken by several jobs. A command registers them once for all its jobs,
+// as the run command accepts the flags of all the jobs.
+type sharedFlags struct {
+	dryRun      bool
+	pullRequest pullRequestFlags
+}
+
+// register registers the flags taken by any of the jobs.
+func (f *sharedFlags) register(flags *flag.FlagSet, definitions ...jobDefinition) {
+	if slices.ContainsFunc(definitions, func(definition jobDefinition) bool { return definition.dryRun }) {
+		flags.BoolVar(&f.dryRun, "dry-run", false, "print the planned changes without making them")
+	}
+	if slices.ContainsFunc(definitions, func(definition jobDefinition) bool { return definition.pullRequests }) {
+		f.pullRequest.register(flags)
+	}
+}
+
+// pullRequestOptions returns the options of the jobs changing files through pull requests.
+func (f *sharedFlags) pullRequestOptions() job.UpdateWorkflowOptions {
+	options := f.pullRequest.options()
+	options.DryRun = f.dryRun
+
+	return options
+}
+
+// announce tells that nothing will be changed on GitHub in a dry run.
+func (f *sharedFlags) announce() {
+	if f.dryRun {
+		printer := pretty.NewScopePrinter("")
+		printer.Info("Dry run: nothing will be changed on GitHub")
+	}
+}
+
+// pullRequestFlags holds the flags describing the pull requests of the jobs changing files and how they are merged.
+type pullRequestFlags struct {
+	merge          bool
+	mergeMethod    string
+	autoMerge      bool
+	waitForChecks  bool
+	checksTimeout  time.Duration
+	checksInterval time.Duration
+	baseBranches   mapFlag
+	title          string
+	body           string
+	milestone      string
+	draft          bool
+	labels         stringList
+	assignees      stringList
+	reviewers      stringList
+	teamReviewers  stringList
+	flags          *flag.FlagSet
+}
+
+func (f *pullRequestFlags) register(flags *flag.FlagSet) {
+	f.flags = flags
+	flags.BoolVar(&f.merge, "merge", false, "merge the created pull requests")
+	flags.StringVar(&f.mergeMethod, "merge-method", job.DefaultMergeMethod, "method the pull requests are merged with: merge, squash or rebase")
+	flags.BoolVar(&f.autoMerge, "auto-merge", false, "enable the auto-merge of the pull requests instead of merging them; requires -merge")
+	flags.BoolVar(&f.waitForChecks, "wait-for-checks", false, "merge the pull requests once their checks pass; requires -merge")
+	flags.DurationVar(&f.checksTimeout, "checks-timeout", job.DefaultChecksTimeout, "time to wait for the checks of a pull request")
+	flags.DurationVar(&f.checksInterval, "checks-interval", job.DefaultChecksInterval, "interval the checks of the pull requests are polled at")
+	flags.Var(&f.baseBranches, "base-branch", "repository=branch pair overriding the default branch the pull request is opened against; can be repeated")
+	flags.StringVar(&f.title, "pr-title", job.DefaultPRTitle, "title template of the pull requests")
+	flags.StringVar(&f.body, "pr-body", "", "body template of the pull requests; a table of the changed files if empty")
+	flags.StringVar(&f.milestone, "milestone", "", "title of the open milestone the pull requests are added to")
+	flags.BoolVar(&f.draft, "draft", false, "open the pull requests as drafts")
+	flags.Var(&f.labels, "label", "label added to the pull requests; can be repeated")
+	flags.Var(&f.assignees, "assignee", "user assigned to the pull requests; can be repeated")
+	flags.Var(&f.reviewers, "reviewer", "user requested to review the pull requests; can be repeated")
+	flags.Var(&f.teamReviewers, "team-reviewer", "team requested to review the pull requests; can be repeated")
+}
+
+// options returns the pull request options given by the flags, falling back to the configuration file.
+func (f *pullRequestFlags) options() job.UpdateWorkflowOptions {
+	config := tool.GetConfig()
+
+	return job.UpdateWorkflowOptions{
+		Merge:          option(f.flags, "merge", f.merge, config.Merge.Enabled),
+		MergeMethod:    option(f.flags, "merge-method", f.mergeMethod, config.Merge.Method),
+		AutoMerge:      option(f.flags, "auto-merge", f.autoMerge, config.Merge.Auto),
+		WaitForChecks:  option(f.flags, "wait-for-checks", f.waitForChecks, config.Merge.WaitForChecks),
+		ChecksTimeout:  option(f.flags, "checks-timeout", f.checksTimeout, config.Merge.ChecksTimeout),
+		ChecksInterval: option(f.flags, "checks-interval", f.checksInterval, config.Merge.ChecksInterval),
+		PullRequest: job.PullRequestOptions{
+			Title:         option(f.flags, "pr-title", f.title, config.PullRequest.Title),
+			Body:          option(f.flags, "pr-body", f.body, config.PullRequest.Body),
+			Labels:        listOption(f.flags, "label", f.labels, config.PullRequest.Labels),
+			Assignees:     listOption(f.flags, "assignee", f.assignees, config.PullRequest.Assignees),
+			Reviewers:     listOption(f.flags, "reviewer", f.reviewers, config.PullRequest.Reviewers),
+			TeamReviewers: listOption(f.flags, "team-reviewer", f.teamReviewers, config.PullRequest.TeamReviewers),
+			Milestone:     option(f.flags, "milestone", f.milestone, config.PullRequest.Milestone),
+			Draft:         option(f.flags, "draft", f.draft, config.PullRequest.Draft),
+		},
+		Overrides: repoOverrides(config.Repositories, f.baseBranches),
+	}
+}
+
+// repoOverrides returns the repository settings of the configuration file with the base branches given by the flags applied.
+func repoOverrides(repositories map[string]tool.RepositoryConfig, baseBranches map[string]string) map[string]job.RepoOverride {
+	overrides := make(map[string]job.RepoOverride, len(repositories)+len(baseBranches))
+	for name, repository := range repositories {
+		overrides[name] = job.RepoOverride{
+			BaseBranch:  repository.BaseBranch,
+			TemplateSet: repository.TemplateSet,
+			Values:      repository.Values,
+			Keep:        repository.Keep,
+			Labels:      repository.Labels,
+			Assignees:   repository.Assignees,
+			Reviewers:   repository.Reviewers,
+		}
+	}
+
+	for name, branch := range baseBranches {
+		override := overrides[name]
+		override.BaseBranch = branch
+		overrides[name] = override
+	}
+
+	return overrides
+}
+
+// mergeMaps returns the configured values with the values given by the flags applied.
+func mergeMaps(configured, given map[string]string) map[string]string {
+	merged := make(map[string]string, len(configured)+len(given))
+	for name, value := range configured {
+		merged[name] = value
+	}
+	for name, value := range given {
+		merged[name] = value
+	}
+
+	return merged
+}
+
+func setupCleanupBranches(flags *flag.FlagSet, shared *sharedFlags) func(ctx context.Context) (job.Job, error) {
+	minAge := flags.Duration("min-age", job.DefaultBranchMinAge, "age a robot branch must reach to be deleted")
+
+	return func(context.Context) (job.Job, error) {
+		return job.NewDeleteOldRobotBranchesJob(option(flags, "min-age", *minAge, tool.GetConfig().Cleanup.MinAge), shared.dryRun), nil
+	}
+}
+
+func setupListRepos(*flag.FlagSet, *sharedFlags) func(ctx context.Context) (job.Job, error) {
+	return func(context.Context) (job.Job, error) {
+		return job.NewListReposJob(), nil
+	}
+}
+
+func setupListTemplates(flags *flag.FlagSet) func(ctx context.Context) error {
+	var templates templateFlags
+	templates.register(flags)
+
+	return func(ctx context.Context) error {
+		infos, err := job.ListTemplates(templates.options())
+		if err != nil {
+			return err
+		}
+
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "SET\tTEMPLATE\tSOURCE")
+		for _, info := range infos {
+			fmt.Fprintf(table, "%s\t%s\t%s\n", info.Set, info.Name, info.Origin)
+		}
+
+		return table.Flush()
+	}
+}
@@ -0,0 +1,183 @@
+// Package diff produces line-based unified diffs of small text files.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around every change.
+const contextLines = 3
+
+// noNewline follows the last line of a text missing its final newline, as in the diffs of git.
+const noNewline = "\n\\ No newline at end of file"
+
+type opKind byte
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns the unified diff between oldText and newText. An empty string is returned if the texts are equal.
+func Unified(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	ops := lineOps(splitLines(oldText), splitLines(newText))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+
+	// positions of the first line of every op in the old and new texts
+	oldPos := make([]int, len(ops)+1)
+	newPos := make([]int, len(ops)+1)
+	for i, o := range ops {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if o.kind != opInsert {
+			oldPos[i+1]++
+		}
+		if o.kind != opDelete {
+			newPos[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == opEqual {
+			i++
+			continue
+		}
+
+		// extend the hunk while the gap between changes is small enough to share the context
+		start := max(i-contextLines, 0)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+
+			gap := end
+			for gap < len(ops) && ops[gap].kind == opEqual {
+				gap++
+			}
+			if gap == len(ops) || gap-end > 2*contextLines {
+				end = min(end+contextLines, len(ops))
+				break
+			}
+			end = gap
+		}
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(oldPos[start], oldPos[end]-oldPos[start]),
+			hunkRange(newPos[start], newPos[end]-newPos[start]),
+		)
+		for _, o := range ops[start:end] {
+			switch o.kind {
+			case opEqual:
+				b.WriteString(" ")
+			case opDelete:
+				b.WriteString("-")
+			case opInsert:
+				b.WriteString("+")
+			}
+			b.WriteString(o.line)
+			b.WriteString("\n")
+		}
+
+		i = end
+	}
+
+	return b.String()
+}
+
+// Stat returns the number of lines added and removed turning oldText into newText.
+func Stat(oldText, newText string) (added, removed int) {
+	if oldText == newText {
+		return 0, 0
+	}
+
+	for _, o := range lineOps(splitLines(oldText), splitLines(newText)) {
+		switch o.kind {
+		case opInsert:
+			added++
+		case opDelete:
+			removed++
+		}
+	}
+
+	return added, removed
+}
+
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// splitLines returns the lines of the text. A last line missing its newline differs from the same line with one.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	trimmed, ok := strings.CutSuffix(text, "\n")
+	lines := strings.Split(trimmed, "\n")
+	if !ok {
+		lines[len(lines)-1] += noNewline
+	}
+	return lines
+}
+
+// lineOps returns the shortest edit script turning a into b, computed with the longest common subsequence.
+func lineOps(a, b []string) []op {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]op, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{opEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{opDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, op{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{opDelete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{opInsert, b[j]})
+	}
+
+	return ops
+}
@@ -0,0 +1,77 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+		want    string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"created", "", "a\nb\n", "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"deleted", "a\n", "", "--- old\n+++ new\n@@ -1 +0,0 @@\n-a\n"},
+		{
+			"changed line in the middle",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			"--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			"two hunks",
+			"a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n",
+			"A\n1\n2\n3\n4\n5\n6\n7\n8\nB\n",
+			"--- old\n+++ new\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -7,4 +7,4 @@\n 6\n 7\n 8\n-b\n+B\n",
+		},
+		{
+			"newline added at the end",
+			"a\nb",
+			"a\nb\n",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			"newline removed at the end",
+			"a\nb\n",
+			"a\nc",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			"unchanged last line without newline",
+			"a\nb",
+			"A\nb",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n-a\n+A\n b\n\\ No newline at end of file\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("old", "new", tt.oldText, tt.newText); got != tt.want {
+				t.Errorf("Unified() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStat(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldText     string
+		newText     string
+		wantAdded   int
+		wantRemoved int
+	}{
+		{"equal", "a\nb\n", "a\nb\n", 0, 0},
+		{"created", "", "a\nb\n", 2, 0},
+		{"deleted", "a\n", "", 0, 1},
+		{"changed and added", "a\nb\nc\n", "a\nB\nc\nd\n", 2, 1},
+		{"newline added at the end", "a\nb", "a\nb\n", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := Stat(tt.oldText, tt.newText)
+			if added != tt.wantAdded || removed != tt.wantRemoved {
+				t.Errorf("Stat() = +%d -%d, want +%d -%d", added, removed, tt.wantAdded, tt.wantRemoved)
+			}
+		})
+	}
+}
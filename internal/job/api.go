@@ -0,0 +1,274 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v60/github"
+	"github.com/kaatinga/robot/internal/retry"
+)
+
+// The safe API calls are retried by the transport of the client. The helpers below retry
+// the calls that create, merge or delete something, taking care of the conflicts a retry can run into.
+
+// isTransient reports whether the API call failed with an error that may disappear on retry.
+func isTransient(err error) bool {
+	var errorResponse *github.ErrorResponse
+	if errors.As(err, &errorResponse) {
+		return retry.IsTransientStatus(errorResponse.Response.StatusCode)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// hasStatus reports whether the API call failed with one of the HTTP status codes.
+func hasStatus(err error, codes ...int) bool {
+	var errorResponse *github.ErrorResponse
+	if !errors.As(err, &errorResponse) {
+		return false
+	}
+
+	for _, code := range codes {
+		if errorResponse.Response.StatusCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// alreadyExists reports whether the API call failed because the object it creates already exists.
+// It happens when a retried call had in fact succeeded before.
+func alreadyExists(err error) bool {
+	return hasStatus(err, http.StatusUnprocessableEntity) && strings.Contains(err.Error(), "already exists")
+}
+
+// createTree creates a tree, retrying on transient failures. Trees are content-addressed, so a retry is harmless.
+func createTree(ctx context.Context, owner, repo, baseTree string, entries []*github.TreeEntry) (tree *github.Tree, err error) {
+	err = retry.Do(ctx, retry.DefaultPolicy, isTransient, func() error {
+		tree, _, err = client.Git.CreateTree(ctx, owner, repo, baseTree, entries)
+		return err
+	})
+
+	return
+}
+
+// createCommit creates a commit, retrying on transient failures. Commits are content-addressed too.
+func createCommit(ctx context.Context, owner, repo string, commit *github.Commit) (created *github.Commit, err error) {
+	err = retry.Do(ctx, retry.DefaultPolicy, isTransient, func() error {
+		created, _, err = client.Git.CreateCommit(ctx, owner, repo, commit, nil)
+		return err
+	})
+
+	return
+}
+
+// createRef creates the branch pointing to the commit. If the branch already exists and points to the commit,
+// e.g. because an earlier attempt succeeded though its response was lost, the branch is considered created.
+func createRef(ctx context.Context, owner, repo, branch, sha string) error {
+	ref := &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: github.String(sha)},
+	}
+
+	err := retry.Do(ctx, retry.DefaultPolicy, isTransient, func() error {
+		_, _, err := client.Git.CreateRef(ctx, owner, repo, ref)
+		return err
+	})
+	if err == nil || !alreadyExists(err) {
+		return err
+	}
+
+	existing, _, getErr := client.Git.GetRef(ctx, owner, repo, "refs/heads/"+branch)
+	if getErr != nil || existing.GetObject().GetSHA() != sha {
+		return err
+	}
+
+	return nil
+}
+
+// updateRef forces the branch onto the commit.
+func updateRef(ctx context.Context, owner, repo, branch, sha string) error {
+	ref := &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: github.String(sha)},
+	}
+
+	return retry.Do(ctx, retry.DefaultPolicy, isTransient, func() error {
+		_, _, err := client.Git.UpdateRef(ctx, owner, repo, ref, true)
+		return err
+	})
+}
+
+// createPullRequest opens the pull request. If a retry finds the pull request already open, the open one is returned.
+func createPullRequest(ctx context.Context, owner, repo string, pr *github.NewPullRequest) (*github.PullRequest, error) {
+	var created *github.PullRequest
+	err := retry.Do(ctx, retry.DefaultPolicy, isTransient, func() (err error) {
+		created, _, err = client.PullRequests.Create(ctx, owner, repo, pr)
+		return err
+	})
+	if err == nil || !alreadyExists(err) {
+		return created, err
+	}
+
+	open, _, listErr := client.PullRequests.List(ctx, owner, repo, &github.PullRequestListOptions{
+		State: "open",
+		Head:  owner + ":" + pr.GetHead(),
+		Base:  pr.GetBase(),
+	})
+	if listErr != nil || len(open) == 0 {
+		return nil, err
+	}
+
+	return open[0], nil
+}
+
+// mergePullRequest merges the pull request. The merge is retried while GitHub reports the pull request
+// as not mergeable yet or the branches as modified concurrently. A pull request found merged when the merge
+// is rejected, e.g. because an earlier attempt succeeded though its response was lost, is considered merged.
+func mergePullRequest(ctx context.Context, owner, repo string, number int, commitMessage string, options *github.PullRequestOptions) error {
+	conflict := func(err error) bool {
+		return isTransient(err) || hasStatus(err, http.StatusMethodNotAllowed, http.StatusConflict)
+	}
+
+	return retry.Do(ctx, retry.DefaultPolicy, conflict, func() error {
+		result, _, err := client.PullRequests.Merge(ctx, owner, repo, number, commitMessage, options)
+		if hasStatus(err, http.StatusMethodNotAllowed) {
+			if pr, _, getErr := client.PullRequests.Get(ctx, owner, repo, number); getErr == nil && pr.GetMerged() {
+				return nil
+			}
+		}
+		if err == nil && !result.GetMerged() {
+			err = fmt.Errorf("pull request #%d not merged: %s", number, result.GetMessage())
+		}
+		return err
+	})
+}
+
+// deleteBranch deletes the branch, retrying on transient failures. A branch missing on a retry is considered
+// deleted, as an earlier attempt may have succeeded though its response was lost.
+func deleteBranch(ctx context.Context, owner, repo, branch string) error {
+	var attempts int
+	err := retry.Do(ctx, retry.DefaultPolicy, isTransient, func() error {
+		attempts++
+		_, err := client.Git.DeleteRef(ctx, owner, repo, "refs/heads/"+branch)
+		return err
+	})
+	if attempts > 1 && hasStatus(err, http.StatusUnprocessableEntity) && strings.Contains(err.Error(), "Reference does not exist") {
+		return nil
+	}
+
+	return err
+}
+
+// enableAutoMerge enables the native auto-merge of the pull request, so that GitHub merges it with the method
+// once the requirements of the base branch are met. The REST API has no endpoint for it, hence the GraphQL mutation.
+func enableAutoMerge(ctx context.Context, pr *github.PullRequest, method string) error {
+	const mutation = `mutation($id: ID!, $method: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method}) { clientMutationId }
+}`
+	body := map[string]any{
+		"query":     mutation,
+		"variables": map[string]string{"id": pr.GetNodeID(), "method": strings.ToUpper(method)},
+	}
+
+	var response struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	err := retry.Do(ctx, retry.DefaultPolicy, isTransient, func() error {
+		req, err := client.NewRequest(http.MethodPost, "graphql", body)
+		if err != nil {
+			return err
+		}
+		_, err = client.Do(ctx, req, &response)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error enabling auto-merge of pull request #%d: %w", pr.GetNumber(), err)
+	}
+	if len(response.Errors) != 0 {
+		return fmt.Errorf("error enabling auto-merge of pull request #%d: %s", pr.GetNumber(), response.Errors[0].Message)
+	}
+
+	return nil
+}
+
+// addPullRequestMetadata adds the labels, the assignees and the milestone to the pull request and requests the reviews.
+func addPullRequestMetadata(ctx context.Context, owner, repo string, number int, metadata pullRequestMetadata) error {
+	if len(metadata.labels) != 0 {
+		err := retry.Do(ctx, retry.DefaultPolicy, isTransient, func() error {
+			_, _, err := client.Issues.AddLabelsToIssue(ctx, owner, repo, number, metadata.labels)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error adding labels to pull request #%d: %w", number, err)
+		}
+	}
+
+	if len(metadata.assignees) != 0 {
+		err := retry.Do(ctx, retry.DefaultPolicy, isTransient, func() error {
+			_, _, err := client.Issues.AddAssignees(ctx, owner, repo, number, metadata.assignees)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error assigning pull request #%d: %w", number, err)
+		}
+	}
+
+	if metadata.milestone != "" {
+		milestone, err := findMilestone(ctx, owner, repo, metadata.milestone)
+		if err != nil {
+			return err
+		}
+
+		err = retry.Do(ctx, retry.DefaultPolicy, isTransient, func() error {
+			_, _, err := client.Issues.Edit(ctx, owner, repo, number, &github.IssueRequest{Milestone: github.Int(milestone)})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error adding pull request #%d to milestone '%s': %w", number, metadata.milestone, err)
+		}
+	}
+
+	if len(metadata.reviewers) != 0 || len(metadata.teamReviewers) != 0 {
+		request := github.ReviewersRequest{Reviewers: metadata.reviewers, TeamReviewers: metadata.teamReviewers}
+		err := retry.Do(ctx, retry.DefaultPolicy, isTransient, func() error {
+			_, _, err := client.PullRequests.RequestReviewers(ctx, owner, repo, number, request)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error requesting reviews of pull request #%d: %w", number, err)
+		}
+	}
+
+	return nil
+}
+
+// findMilestone returns the number of the open milestone with the title.
+func findMilestone(ctx context.Context, owner, repo, title string) (int, error) {
+	options := &github.MilestoneListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		milestones, resp, err := client.Issues.ListMilestones(ctx, owner, repo, options)
+		if err != nil {
+			return 0, fmt.Errorf("error listing milestones: %w", err)
+		}
+
+		for _, milestone := range milestones {
+			if milestone.GetTitle() == title {
+				return milestone.GetNumber(), nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return 0, fmt.Errorf("no open milestone '%s' found", title)
+		}
+		options.Page = resp.NextPage
+	}
+}
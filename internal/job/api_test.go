@@ -0,0 +1,126 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// apiResponse is a canned response of the test server.
+type apiResponse struct {
+	code int
+	body string
+}
+
+// serveResponses answers the requests with the responses in turn and records the requests.
+func serveResponses(t *testing.T, responses []apiResponse) *[]string {
+	t.Helper()
+
+	var requests []string
+	useTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if len(requests) > len(responses) {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		response := responses[len(requests)-1]
+		w.WriteHeader(response.code)
+		fmt.Fprint(w, response.body)
+	}))
+
+	return &requests
+}
+
+func Test_mergePullRequest(t *testing.T) {
+	const (
+		merge = "PUT /repos/kaatinga/robot/pulls/7/merge"
+		get   = "GET /repos/kaatinga/robot/pulls/7"
+	)
+	notMergeable := apiResponse{http.StatusMethodNotAllowed, `{"message": "Pull Request is not mergeable"}`}
+
+	tests := []struct {
+		name         string
+		responses    []apiResponse
+		wantRequests []string
+		wantErr      bool
+	}{
+		{
+			name:         "merged",
+			responses:    []apiResponse{{http.StatusOK, `{"merged": true}`}},
+			wantRequests: []string{merge},
+		},
+		{
+			name: "merged though the response was lost",
+			responses: []apiResponse{
+				{http.StatusBadGateway, ""},
+				notMergeable,
+				{http.StatusOK, `{"number": 7, "merged": true}`},
+			},
+			wantRequests: []string{merge, merge, get},
+		},
+		{
+			name: "not mergeable yet",
+			responses: []apiResponse{
+				notMergeable,
+				{http.StatusOK, `{"number": 7, "merged": false}`},
+				{http.StatusOK, `{"merged": true}`},
+			},
+			wantRequests: []string{merge, get, merge},
+		},
+		{
+			name:         "conflict with the base branch",
+			responses:    []apiResponse{{http.StatusUnprocessableEntity, `{"message": "Merge conflict"}`}},
+			wantRequests: []string{merge},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := serveResponses(t, tt.responses)
+
+			err := mergePullRequest(context.Background(), "kaatinga", "robot", 7, "Merging PR", nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("mergePullRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(*requests, tt.wantRequests) {
+				t.Errorf("mergePullRequest() requests = %v, want %v", *requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func Test_deleteBranch(t *testing.T) {
+	missing := apiResponse{http.StatusUnprocessableEntity, `{"message": "Reference does not exist"}`}
+
+	tests := []struct {
+		name      string
+		responses []apiResponse
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "deleted", responses: []apiResponse{{http.StatusNoContent, ""}}, wantCalls: 1},
+		{name: "deleted though the response was lost", responses: []apiResponse{{http.StatusBadGateway, ""}, missing}, wantCalls: 2},
+		{name: "missing", responses: []apiResponse{missing}, wantCalls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := serveResponses(t, tt.responses)
+
+			err := deleteBranch(context.Background(), "kaatinga", "robot", "robot-works-2024-01-01T000000Z")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("deleteBranch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(*requests) != tt.wantCalls {
+				t.Errorf("deleteBranch() requests = %v, want %d", *requests, tt.wantCalls)
+			}
+			for _, request := range *requests {
+				if request != "DELETE /repos/kaatinga/robot/git/refs/heads/robot-works-2024-01-01T000000Z" {
+					t.Errorf("unexpected request %s", request)
+				}
+			}
+		})
+	}
+}
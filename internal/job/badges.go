@@ -0,0 +1,294 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// readmeNames are the names of the READMEs the badges are inserted in, in the order they are looked up.
+var readmeNames = []string{"README.md", "README.markdown", "README.rst"}
+
+// markers delimiting the badges in a README, so that the robot refreshes only its own badges
+const (
+	badgesStartMarkdown = "<!-- robot:badges:start -->"
+	badgesEndMarkdown   = "<!-- robot:badges:end -->"
+	badgesStartRST      = ".. robot:badges:start"
+	badgesEndRST        = ".. robot:badges:end"
+)
+
+// badgesJob inserts the badges of the workflows managed by the robot, and the coverage badge of Codecov if one of
+// them uploads the coverage there, in the README of the repositories.
+type badgesJob struct {
+	// update commits the changes of the badges to a pull request
+	update *updateWorkflowFilesJob
+}
+
+// NewBadgesJob returns the job inserting the badges through pull requests described by the options.
+// The templates of the options are ignored.
+func NewBadgesJob(options UpdateWorkflowOptions) (*badgesJob, error) {
+	update, err := newUpdateJob(options, nil, badgesBranchPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &badgesJob{update: update}
+	update.changers = []fileChanger{j}
+	return j, nil
+}
+
+func (j *badgesJob) Name() string {
+	return "badges"
+}
+
+func (j *badgesJob) Outcomes() []string {
+	return j.update.Outcomes()
+}
+
+// Run opens a pull request inserting or refreshing the badges in the README of the repository.
+func (j *badgesJob) Run(ctx context.Context, repo RepoContext) (RepoResult, error) {
+	return j.update.Run(ctx, repo)
+}
+
+// fileChanges returns the change of the README if its badges differ from the workflows managed by the robot.
+func (j *badgesJob) fileChanges(ctx context.Context, repo RepoContext, base baseState) ([]fileChange, error) {
+	printer := repo.Printer.WithPrefix("-----")
+
+	readme, sha := findReadme(base.files)
+	if readme == "" {
+		printer.Skipped("No README to insert the badges in.")
+		return nil, nil
+	}
+
+	content, _, err := client.Git.GetBlobRaw(ctx, repo.Owner, repo.Name, sha)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving '%s': %w", readme, err)
+	}
+
+	badges := workflowBadges(repo.Owner, repo.Name, base.branch, base.managed)
+	coverage, err := usesCodecov(ctx, repo, base)
+	if err != nil {
+		return nil, err
+	}
+	if coverage {
+		badges = append(badges, codecovBadge(repo.Owner, repo.Name, base.branch))
+	}
+	updated := insertBadges(content, badges, strings.HasSuffix(readme, ".rst"))
+	if blobSHA(updated) == sha {
+		printer.Skipped("Badges in '%s' are up to date.", readme)
+		return nil, nil
+	}
+
+	return []fileChange{{path: readme, action: updateAction, content: updated, sha: sha}}, nil
+}
+
+// findReadme returns the path and the blob SHA of the README in the root of the repository.
+// It returns an empty path if there is no README the badges can be inserted in.
+func findReadme(files map[string]string) (string, string) {
+	for _, name := range readmeNames {
+		for _, filePath := range sortedKeys(files) {
+			if strings.EqualFold(filePath, name) {
+				return filePath, files[filePath]
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// badge is the status badge of a workflow.
+type badge struct {
+	label    string
+	imageURL string
+	linkURL  string
+}
+
+// workflowBadges returns the badges of the managed workflows, ordered by their files.
+func workflowBadges(owner, repo, branch string, managed manifest) []badge {
+	var badges []badge
+	for _, filePath := range sortedKeys(managed) {
+		if !isWorkflow(filePath) {
+			continue
+		}
+		file := path.Base(filePath)
+		ext := path.Ext(file)
+
+		link := fmt.Sprintf("https://github.com/%s/%s/actions/workflows/%s", owner, repo, file)
+		badges = append(badges, badge{
+			label:    strings.TrimSuffix(file, ext),
+			imageURL: link + "/badge.svg?branch=" + branch,
+			linkURL:  link,
+		})
+	}
+
+	return badges
+}
+
+// codecovBadge returns the coverage badge of the repository on Codecov.
+func codecovBadge(owner, repo, branch string) badge {
+	link := fmt.Sprintf("https://codecov.io/gh/%s/%s", owner, repo)
+	return badge{
+		label:    "coverage",
+		imageURL: link + "/branch/" + branch + "/graph/badge.svg",
+		linkURL:  link,
+	}
+}
+
+// usesCodecov reports whether one of the managed workflows uploads the coverage to Codecov.
+// The workflows rendered for the same pull request are looked at instead of the ones on the base branch.
+func usesCodecov(ctx context.Context, repo RepoContext, base baseState) (bool, error) {
+	for _, filePath := range sortedKeys(base.managed) {
+		if !isWorkflow(filePath) {
+			continue
+		}
+
+		content, rendered := base.rendered[filePath]
+		if !rendered {
+			sha, exists := base.files[filePath]
+			if !exists {
+				continue
+			}
+			var err error
+			if content, _, err = client.Git.GetBlobRaw(ctx, repo.Owner, repo.Name, sha); err != nil {
+				return false, fmt.Errorf("error retrieving '%s': %w", filePath, err)
+			}
+		}
+
+		if usesAction(content, "codecov/codecov-action") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// usesAction reports whether a step of the workflow uses the action, whatever its version.
+func usesAction(workflow []byte, action string) bool {
+	for _, line := range strings.Split(string(workflow), "\n") {
+		line = strings.TrimPrefix(strings.TrimSpace(line), "- ")
+		uses, found := strings.CutPrefix(line, "uses:")
+		if !found {
+			continue
+		}
+		uses, _, _ = strings.Cut(uses, " #")
+		uses = strings.Trim(strings.TrimSpace(uses), `"'`)
+		if uses == action || strings.HasPrefix(uses, action+"@") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isWorkflow reports whether the file is a workflow of GitHub Actions.
+func isWorkflow(filePath string) bool {
+	dir, file := path.Split(filePath)
+	ext := path.Ext(file)
+	return dir == ".github/workflows/" && (ext == ".yml" || ext == ".yaml")
+}
+
+// insertBadges returns the README with the block of the badges replaced, or inserted after the title if there is none.
+// Without badges, the block is removed. The rst flag selects reStructuredText instead of Markdown.
+func insertBadges(readme []byte, badges []badge, rst bool) []byte {
+	start, end := badgesStartMarkdown, badgesEndMarkdown
+	if rst {
+		start, end = badgesStartRST, badgesEndRST
+	}
+
+	var block []string
+	if len(badges) != 0 {
+		block = append(block, start)
+		for _, b := range badges {
+			if rst {
+				block = append(block, ".. image:: "+b.imageURL, "   :target: "+b.linkURL, "   :alt: "+b.label)
+			} else {
+				block = append(block, fmt.Sprintf("[![%s](%s)](%s)", b.label, b.imageURL, b.linkURL))
+			}
+		}
+		block = append(block, end)
+	}
+
+	lines := strings.Split(string(readme), "\n")
+	startAt, endAt := markerLine(lines, start, 0), -1
+	if startAt >= 0 {
+		endAt = markerLine(lines, end, startAt+1)
+	}
+
+	var result []string
+	switch {
+	case startAt >= 0 && endAt >= 0:
+		result = append(result, lines[:startAt]...)
+		result = append(result, block...)
+		rest := lines[endAt+1:]
+		// the blank line after a removed block goes with it
+		if len(block) == 0 && len(rest) != 0 && rest[0] == "" && startAt > 0 && lines[startAt-1] == "" {
+			rest = rest[1:]
+		}
+		result = append(result, rest...)
+	case len(block) == 0:
+		return readme
+	default:
+		at := titleEnd(lines, rst)
+		result = append(result, lines[:at]...)
+		if at > 0 {
+			result = append(result, "")
+		}
+		result = append(result, block...)
+		if at >= len(lines) || lines[at] != "" {
+			result = append(result, "")
+		}
+		result = append(result, lines[at:]...)
+	}
+
+	return []byte(strings.Join(result, "\n"))
+}
+
+// markerLine returns the index of the line holding the marker, starting at the line from, or -1 if there is none.
+func markerLine(lines []string, marker string, from int) int {
+	for i := from; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == marker {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// titleEnd returns the index of the line after the title the badges follow, or 0 if the README has no title
+// before its first paragraph.
+func titleEnd(lines []string, rst bool) int {
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			continue
+		case rst && isRSTAdornment(line):
+			// the title is overlined and underlined with punctuation
+			if i+2 < len(lines) && isRSTAdornment(lines[i+2]) {
+				return i + 3
+			}
+		case rst:
+			// the title is underlined with punctuation
+			if i+1 < len(lines) && isRSTAdornment(lines[i+1]) {
+				return i + 2
+			}
+		case strings.HasPrefix(trimmed, "# "):
+			return i + 1
+		}
+
+		return 0
+	}
+
+	return 0
+}
+
+// isRSTAdornment reports whether the line underlines or overlines a reStructuredText title.
+func isRSTAdornment(line string) bool {
+	line = strings.TrimSpace(line)
+	if len(line) < 3 {
+		return false
+	}
+
+	return strings.Count(line, line[:1]) == len(line) && strings.ContainsAny(line[:1], "=-~^\"'`#*+:._")
+}
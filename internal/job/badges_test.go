@@ -0,0 +1,288 @@
+package job
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kaatinga/robot/internal/pretty"
+)
+
+func Test_insertBadges(t *testing.T) {
+	badges := workflowBadges("kaatinga", "robot", "main", manifest{
+		".github/workflows/test.yml":  true,
+		".github/workflows/lint.yaml": true,
+		".github/dependabot.yml":      true,
+		".golangci.yml":               true,
+	})
+	const lintBadge = "[![lint](https://github.com/kaatinga/robot/actions/workflows/lint.yaml/badge.svg?branch=main)](https://github.com/kaatinga/robot/actions/workflows/lint.yaml)"
+	const testBadge = "[![test](https://github.com/kaatinga/robot/actions/workflows/test.yml/badge.svg?branch=main)](https://github.com/kaatinga/robot/actions/workflows/test.yml)"
+	const block = badgesStartMarkdown + "\n" + lintBadge + "\n" + testBadge + "\n" + badgesEndMarkdown
+
+	tests := []struct {
+		name   string
+		readme string
+		badges []badge
+		rst    bool
+		want   string
+	}{
+		{
+			name:   "after the title",
+			readme: "# robot\n\nKeeps the workflows up to date.\n",
+			badges: badges,
+			want:   "# robot\n\n" + block + "\n\nKeeps the workflows up to date.\n",
+		},
+		{
+			name:   "without a title",
+			readme: "Keeps the workflows up to date.\n",
+			badges: badges,
+			want:   block + "\n\nKeeps the workflows up to date.\n",
+		},
+		{
+			name:   "empty",
+			readme: "",
+			badges: badges,
+			want:   block + "\n",
+		},
+		{
+			name:   "refreshed",
+			readme: "# robot\n\n" + badgesStartMarkdown + "\n[![old](old.svg)](old)\n" + badgesEndMarkdown + "\n\nText\n",
+			badges: badges,
+			want:   "# robot\n\n" + block + "\n\nText\n",
+		},
+		{
+			name:   "up to date",
+			readme: "# robot\n\n" + block + "\n\nText\n",
+			badges: badges,
+			want:   "# robot\n\n" + block + "\n\nText\n",
+		},
+		{
+			name:   "removed without workflows",
+			readme: "# robot\n\n" + block + "\n\nText\n",
+			want:   "# robot\n\nText\n",
+		},
+		{
+			name:   "nothing to remove",
+			readme: "# robot\n",
+			want:   "# robot\n",
+		},
+		{
+			name:   "reStructuredText",
+			readme: "=====\nrobot\n=====\n\nText\n",
+			badges: badges[1:],
+			rst:    true,
+			want: "=====\nrobot\n=====\n\n" + badgesStartRST + "\n" +
+				".. image:: https://github.com/kaatinga/robot/actions/workflows/test.yml/badge.svg?branch=main\n" +
+				"   :target: https://github.com/kaatinga/robot/actions/workflows/test.yml\n" +
+				"   :alt: test\n" + badgesEndRST + "\n\nText\n",
+		},
+		{
+			name:   "reStructuredText underlined",
+			readme: "robot\n-----\nText\n",
+			badges: badges[1:],
+			rst:    true,
+			want: "robot\n-----\n\n" + badgesStartRST + "\n" +
+				".. image:: https://github.com/kaatinga/robot/actions/workflows/test.yml/badge.svg?branch=main\n" +
+				"   :target: https://github.com/kaatinga/robot/actions/workflows/test.yml\n" +
+				"   :alt: test\n" + badgesEndRST + "\n\nText\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(insertBadges([]byte(tt.readme), tt.badges, tt.rst)); got != tt.want {
+				t.Errorf("insertBadges() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_usesAction(t *testing.T) {
+	tests := []struct {
+		name     string
+		workflow string
+		want     bool
+	}{
+		{name: "step", workflow: "    steps:\n      - uses: codecov/codecov-action@v4\n", want: true},
+		{name: "named step", workflow: "      - name: Upload\n        uses: 'codecov/codecov-action@v4' # coverage\n", want: true},
+		{name: "other action", workflow: "      - uses: codecov/test-results-action@v1\n"},
+		{name: "comment", workflow: "      # the coverage used to go to codecov/codecov-action\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usesAction([]byte(tt.workflow), "codecov/codecov-action"); got != tt.want {
+				t.Errorf("usesAction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_usesCodecov(t *testing.T) {
+	const codecov = "jobs:\n  test:\n    steps:\n      - uses: codecov/codecov-action@v4\n"
+	const lint = "jobs:\n  lint:\n    steps:\n      - uses: golangci/golangci-lint-action@v4\n"
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		rendered map[string][]byte
+		want     bool
+	}{
+		{
+			name:  "on the base branch",
+			files: map[string]string{".github/workflows/lint.yml": blobSHA([]byte(lint)), ".github/workflows/test.yml": blobSHA([]byte(codecov))},
+			want:  true,
+		},
+		{
+			name:     "rendered for the pull request",
+			files:    map[string]string{".github/workflows/test.yml": blobSHA([]byte(codecov))},
+			rendered: map[string][]byte{".github/workflows/test.yml": []byte(lint)},
+		},
+		{
+			name:     "created by the pull request",
+			rendered: map[string][]byte{".github/workflows/test.yml": []byte(codecov)},
+			want:     true,
+		},
+		{name: "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, content := range []string{codecov, lint} {
+					if r.URL.Path == "/repos/kaatinga/robot/git/blobs/"+blobSHA([]byte(content)) {
+						fmt.Fprint(w, content)
+						return
+					}
+				}
+				t.Errorf("unexpected request %s %s", r.Method, r.URL)
+				w.WriteHeader(http.StatusNotFound)
+			}))
+
+			base := baseState{
+				branch:   "main",
+				files:    tt.files,
+				managed:  manifest{".github/workflows/lint.yml": true, ".github/workflows/test.yml": true},
+				rendered: tt.rendered,
+			}
+			got, err := usesCodecov(context.Background(), RepoContext{Owner: "kaatinga", Name: "robot"}, base)
+			if err != nil {
+				t.Fatalf("usesCodecov() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("usesCodecov() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_findReadme(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{name: "markdown", files: map[string]string{"README.rst": "1", "README.md": "2"}, want: "README.md"},
+		{name: "lower case", files: map[string]string{"readme.md": "1"}, want: "readme.md"},
+		{name: "reStructuredText", files: map[string]string{"README.rst": "1", "docs/README.md": "2"}, want: "README.rst"},
+		{name: "missing", files: map[string]string{"README.txt": "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := findReadme(tt.files); got != tt.want {
+				t.Errorf("findReadme() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_badgesJob_Run(t *testing.T) {
+	managed := manifest{".github/workflows/test.yml": true}
+	upToDate := "# robot\n\n" + badgesStartMarkdown + "\n" +
+		"[![test](https://github.com/kaatinga/robot/actions/workflows/test.yml/badge.svg?branch=main)](https://github.com/kaatinga/robot/actions/workflows/test.yml)\n" +
+		badgesEndMarkdown + "\n"
+	// the open pull request of update-workflows is never taken over nor closed by the badges job
+	const workflowsPR = `{"number": 3, "head": {"ref": "robot-works-2024-01-01T000000Z", "sha": "workflows", "repo": {"id": 1}}, "base": {"ref": "main", "repo": {"id": 1}}}`
+
+	tests := []struct {
+		name         string
+		readme       string
+		wantRequests []string
+	}{
+		{name: "badges up to date", readme: upToDate},
+		{
+			name:   "badges inserted",
+			readme: "# robot\n",
+			wantRequests: []string{
+				"POST /repos/kaatinga/robot/git/trees",
+				"POST /repos/kaatinga/robot/git/commits",
+				"POST /repos/kaatinga/robot/git/refs " + badgesBranchPrefix,
+				"POST /repos/kaatinga/robot/pulls " + badgesBranchPrefix,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readmeSHA := blobSHA([]byte(tt.readme))
+			var requests []string
+			useTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet {
+					// the branch is given in the body of the new references and pull requests
+					body, _ := io.ReadAll(r.Body)
+					request := r.Method + " " + r.URL.Path
+					if strings.Contains(string(body), badgesBranchPrefix) {
+						request += " " + badgesBranchPrefix
+					}
+					requests = append(requests, request)
+				}
+
+				switch path := strings.TrimPrefix(r.URL.Path, "/repos/kaatinga/robot/"); {
+				case path == "git/ref/heads/main":
+					fmt.Fprint(w, `{"ref": "refs/heads/main", "object": {"sha": "base"}}`)
+				case path == "git/trees/base":
+					fmt.Fprintf(w, `{"sha": "base-tree", "tree": [{"path": "README.md", "type": "blob", "mode": "100644", "sha": %q}]}`, readmeSHA)
+				case path == "contents/.github/.robot-managed":
+					fmt.Fprintf(w, `{"type": "file", "encoding": "base64", "sha": "manifest", "content": %q}`, base64.StdEncoding.EncodeToString(managed.format()))
+				case path == "git/blobs/"+readmeSHA:
+					fmt.Fprint(w, tt.readme)
+				case path == "pulls" && r.Method == http.MethodGet:
+					fmt.Fprint(w, "["+workflowsPR+"]")
+				case path == "pulls":
+					fmt.Fprint(w, `{"number": 9}`)
+				case path == "git/commits/base":
+					fmt.Fprint(w, `{"sha": "base", "tree": {"sha": "base-tree"}}`)
+				case path == "git/trees":
+					fmt.Fprint(w, `{"sha": "new-tree"}`)
+				case path == "git/commits":
+					fmt.Fprint(w, `{"sha": "new"}`)
+				case path == "git/refs":
+					fmt.Fprint(w, `{"ref": "refs/heads/new"}`)
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+
+			j, err := NewBadgesJob(UpdateWorkflowOptions{})
+			if err != nil {
+				t.Fatalf("NewBadgesJob() error = %v", err)
+			}
+			_, err = j.Run(context.Background(), RepoContext{
+				Owner:         "kaatinga",
+				Name:          "robot",
+				FullName:      "kaatinga/robot",
+				DefaultBranch: "main",
+				Printer:       pretty.NewScopePrinterTo(io.Discard, ""),
+			})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if !slices.Equal(requests, tt.wantRequests) {
+				t.Errorf("Run() requests = %v, want %v", requests, tt.wantRequests)
+			}
+		})
+	}
+}
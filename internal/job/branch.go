@@ -1,6 +1,43 @@
 package job
 
+import (
+	"strings"
+	"time"
+)
+
 const (
 	branchSafeTimeFormat = `2006-01-02T150405Z0700`
-	branchPrefix         = "robot-works-"
+	// branchPrefix starts the names of all the robot branches. The branches of update-workflows are named
+	// with it followed by the creation time.
+	branchPrefix = "robot-works-"
+	// badgesBranchPrefix starts the names of the branches of the badges job.
+	badgesBranchPrefix = branchPrefix + "badges-"
 )
+
+// jobBranchPrefixes are the prefixes of the branches of the jobs opening pull requests, the longest first.
+var jobBranchPrefixes = []string{badgesBranchPrefix, branchPrefix}
+
+// ownsBranch reports whether the branch was created by the job whose branches start with the prefix:
+// the prefix is followed by the creation time, so that the branches of the other jobs do not match.
+func ownsBranch(prefix, branch string) bool {
+	rest, found := strings.CutPrefix(branch, prefix)
+	if !found {
+		return false
+	}
+
+	_, err := time.Parse(branchSafeTimeFormat, rest)
+	return err == nil
+}
+
+// branchCreated returns the creation time in the name of the robot branch. It reports false if the name
+// holds no creation time.
+func branchCreated(branch string) (time.Time, bool) {
+	for _, prefix := range jobBranchPrefixes {
+		if rest, found := strings.CutPrefix(branch, prefix); found {
+			created, err := time.Parse(branchSafeTimeFormat, rest)
+			return created, err == nil
+		}
+	}
+
+	return time.Time{}, false
+}
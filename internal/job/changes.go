@@ -0,0 +1,173 @@
+package job
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v60/github"
+)
+
+// fileChange is a change of a single file in a repository.
+type fileChange struct {
+	path   string
+	action action
+	// content is the new content of the file. It is nil if the file is deleted.
+	content []byte
+	// sha is the blob SHA of the current content of the file. It is empty if the file is created.
+	sha string
+	// oldContent is the current content of the file. It is nil if the file is created.
+	oldContent []byte
+	// mode is the git file mode of the current file, e.g. 100755 for an executable. It is empty if the file is created.
+	mode string
+}
+
+// regularFileMode is the git file mode of a regular file, given to the created files.
+const regularFileMode = "100644"
+
+// result returns the result of the change once it is committed.
+func (c fileChange) result() resultAction {
+	switch c.action {
+	case updateAction:
+		return resultUpdated
+	case deleteAction:
+		return resultDeleted
+	case createAction:
+		return resultCreated
+	default:
+		return resultNoAction
+	}
+}
+
+// blobSHA returns the SHA git assigns to a blob with the given content.
+// It lets us compare the files in a repository with the templates without downloading them.
+func blobSHA(content []byte) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "blob %d\x00", len(content))
+	hash.Write(content)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// commitMessage returns the message of the commit containing the changes.
+func commitMessage(changes []fileChange) string {
+	var message strings.Builder
+	message.WriteString("Update files managed by the robot\n")
+	for _, change := range changes {
+		message.WriteString("\n")
+		message.WriteString(change.result().String())
+		message.WriteString(" ")
+		message.WriteString(change.path)
+	}
+
+	return message.String()
+}
+
+// loadOldContents downloads the current content of the updated and deleted files.
+func loadOldContents(ctx context.Context, owner, repo string, changes []fileChange) error {
+	for i, change := range changes {
+		if !change.action.RequiresSHA() {
+			continue
+		}
+
+		content, _, err := client.Git.GetBlobRaw(ctx, owner, repo, change.sha)
+		if err != nil {
+			return fmt.Errorf("error retrieving '%s': %v", change.path, err)
+		}
+		changes[i].oldContent = content
+	}
+
+	return nil
+}
+
+// getTreeBlobs returns the blob SHAs and the file modes of all the files at the commit, keyed by their paths.
+func getTreeBlobs(ctx context.Context, owner, repo, commitSHA string) (blobs, modes map[string]string, err error) {
+	tree, _, err := client.Git.GetTree(ctx, owner, repo, commitSHA, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting the files of commit %s: %w", commitSHA, err)
+	}
+
+	if tree.GetTruncated() {
+		return nil, nil, fmt.Errorf("too many files in commit %s to list them", commitSHA)
+	}
+
+	blobs = make(map[string]string, len(tree.Entries))
+	modes = make(map[string]string, len(tree.Entries))
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" {
+			blobs[entry.GetPath()] = entry.GetSHA()
+			modes[entry.GetPath()] = entry.GetMode()
+		}
+	}
+
+	return blobs, modes, nil
+}
+
+// commitChanges creates a single commit with all the changes on top of the base commit
+// and returns the new commit. The commit is not referenced by any branch yet.
+func commitChanges(ctx context.Context, owner, repo, baseSHA string, changes []fileChange) (*github.Commit, error) {
+	baseCommit, _, err := client.Git.GetCommit(ctx, owner, repo, baseSHA)
+	if err != nil {
+		return nil, fmt.Errorf("error getting base commit: %w", err)
+	}
+
+	tree, err := createTree(ctx, owner, repo, baseCommit.GetTree().GetSHA(), treeEntries(changes))
+	if err != nil {
+		return nil, fmt.Errorf("error creating tree: %w", err)
+	}
+
+	commit, err := createCommit(ctx, owner, repo, &github.Commit{
+		Message: github.String(commitMessage(changes)),
+		Tree:    tree,
+		Parents: []*github.Commit{{SHA: baseCommit.SHA}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating commit: %w", err)
+	}
+
+	return commit, nil
+}
+
+// treeEntries returns the entries of the tree changing the files. The files keep their modes, so that e.g. an
+// updated script stays executable.
+func treeEntries(changes []fileChange) []*github.TreeEntry {
+	entries := make([]*github.TreeEntry, 0, len(changes))
+	for _, change := range changes {
+		mode := change.mode
+		if mode == "" {
+			mode = regularFileMode
+		}
+		entry := &github.TreeEntry{
+			Path: github.String(change.path),
+			Mode: github.String(mode),
+			Type: github.String("blob"),
+		}
+		// an entry without both content and SHA deletes the file
+		if change.action.RequiresContent() {
+			entry.Content = github.String(string(change.content))
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// sameCommit reports whether the existing commit has the same tree and the same parents as the new one.
+func sameCommit(ctx context.Context, owner, repo, existingSHA string, commit *github.Commit) (bool, error) {
+	existing, _, err := client.Git.GetCommit(ctx, owner, repo, existingSHA)
+	if err != nil {
+		return false, fmt.Errorf("error getting commit %s: %w", existingSHA, err)
+	}
+
+	if existing.GetTree().GetSHA() != commit.GetTree().GetSHA() || len(existing.Parents) != len(commit.Parents) {
+		return false, nil
+	}
+	for i, parent := range existing.Parents {
+		if parent.GetSHA() != commit.Parents[i].GetSHA() {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,92 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v60/github"
+)
+
+func Test_blobSHA(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "", "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"},
+		{"hello", "hello\n", "ce013625030ba8dba906f756967f9e9ca394464a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blobSHA([]byte(tt.content)); got != tt.want {
+				t.Errorf("blobSHA() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_treeEntries(t *testing.T) {
+	entries := treeEntries([]fileChange{
+		{path: "scripts/check.sh", action: updateAction, content: []byte("#!/bin/sh\n"), sha: "1", mode: "100755"},
+		{path: "Makefile", action: createAction, content: []byte("all:\n")},
+		{path: "old.yml", action: deleteAction, sha: "2", mode: "100644"},
+		{path: ".nojekyll", action: createAction},
+	})
+
+	want := []github.TreeEntry{
+		{Path: github.String("scripts/check.sh"), Mode: github.String("100755"), Type: github.String("blob"), Content: github.String("#!/bin/sh\n")},
+		{Path: github.String("Makefile"), Mode: github.String("100644"), Type: github.String("blob"), Content: github.String("all:\n")},
+		{Path: github.String("old.yml"), Mode: github.String("100644"), Type: github.String("blob")},
+		{Path: github.String(".nojekyll"), Mode: github.String("100644"), Type: github.String("blob"), Content: github.String("")},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("treeEntries() = %v, want %v", entries, want)
+	}
+	for i, entry := range entries {
+		if entry.GetPath() != want[i].GetPath() || entry.GetMode() != want[i].GetMode() || entry.GetType() != want[i].GetType() ||
+			(entry.Content == nil) != (want[i].Content == nil) || entry.GetContent() != want[i].GetContent() {
+			t.Errorf("treeEntries()[%d] = %v, want %v", i, entry, want[i])
+		}
+	}
+}
+
+func Test_sameCommit(t *testing.T) {
+	commit := &github.Commit{Tree: &github.Tree{SHA: github.String("tree")}, Parents: []*github.Commit{{SHA: github.String("base")}}}
+	tests := []struct {
+		name     string
+		existing string
+		want     bool
+	}{
+		{name: "same", existing: `{"sha": "old", "tree": {"sha": "tree"}, "parents": [{"sha": "base"}]}`, want: true},
+		{name: "other tree", existing: `{"sha": "old", "tree": {"sha": "other"}, "parents": [{"sha": "base"}]}`},
+		{name: "moved base", existing: `{"sha": "old", "tree": {"sha": "tree"}, "parents": [{"sha": "older base"}]}`},
+		{name: "more parents", existing: `{"sha": "old", "tree": {"sha": "tree"}, "parents": [{"sha": "base"}, {"sha": "merged"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/repos/kaatinga/robot/git/commits/old" {
+					t.Errorf("unexpected request %s", r.URL)
+				}
+				fmt.Fprint(w, tt.existing)
+			}))
+
+			got, err := sameCommit(context.Background(), "kaatinga", "robot", "old", commit)
+			if err != nil {
+				t.Fatalf("sameCommit() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("sameCommit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	useTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	if _, err := sameCommit(context.Background(), "kaatinga", "robot", "old", commit); err == nil {
+		t.Error("sameCommit() of a missing commit succeeded")
+	}
+}
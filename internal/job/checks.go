@@ -0,0 +1,143 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v60/github"
+)
+
+// Defaults of waiting for the checks of the pull requests.
+const (
+	DefaultChecksTimeout  = 30 * time.Minute
+	DefaultChecksInterval = 30 * time.Second
+)
+
+// checksState is the overall state of the commit statuses and the check runs of a commit.
+type checksState byte
+
+const (
+	// checksNone means that no status or check run is reported yet.
+	checksNone checksState = iota
+	checksPending
+	checksPassed
+	checksFailed
+)
+
+// evaluateChecks returns the overall state of the statuses and the check runs, along with the names of the failed ones.
+func evaluateChecks(statuses []*github.RepoStatus, runs []*github.CheckRun) (checksState, []string) {
+	if len(statuses) == 0 && len(runs) == 0 {
+		return checksNone, nil
+	}
+
+	var pending bool
+	var failed []string
+	for _, status := range statuses {
+		switch status.GetState() {
+		case "success":
+		case "pending":
+			pending = true
+		default:
+			failed = append(failed, status.GetContext())
+		}
+	}
+	for _, run := range runs {
+		if run.GetStatus() != "completed" {
+			pending = true
+			continue
+		}
+		switch run.GetConclusion() {
+		case "success", "neutral", "skipped":
+		default:
+			failed = append(failed, run.GetName())
+		}
+	}
+
+	switch {
+	case len(failed) != 0:
+		return checksFailed, failed
+	case pending:
+		return checksPending, nil
+	default:
+		return checksPassed, nil
+	}
+}
+
+// getChecks returns the commit statuses and the check runs of the commit.
+func getChecks(ctx context.Context, owner, repo, sha string) ([]*github.RepoStatus, []*github.CheckRun, error) {
+	var statuses []*github.RepoStatus
+	statusOptions := &github.ListOptions{PerPage: 100}
+	for {
+		combined, resp, err := client.Repositories.GetCombinedStatus(ctx, owner, repo, sha, statusOptions)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting commit statuses: %w", err)
+		}
+		statuses = append(statuses, combined.Statuses...)
+		if resp.NextPage == 0 {
+			break
+		}
+		statusOptions.Page = resp.NextPage
+	}
+
+	var runs []*github.CheckRun
+	runOptions := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		result, resp, err := client.Checks.ListCheckRunsForRef(ctx, owner, repo, sha, runOptions)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error listing check runs: %w", err)
+		}
+		runs = append(runs, result.CheckRuns...)
+		if resp.NextPage == 0 {
+			break
+		}
+		runOptions.Page = resp.NextPage
+	}
+
+	return statuses, runs, nil
+}
+
+// waitForChecks polls the statuses and the check runs of the commit until all of them pass, one of them fails
+// or the timeout expires. While nothing is reported for the commit, the checks are waited for, as they may be
+// still queued: the commit never passes without a check.
+func waitForChecks(ctx context.Context, owner, repo, sha string, timeout, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// state is the one of the last poll, telling what the timeout was spent waiting for
+	state := checksNone
+	expired := func() error {
+		if state == checksNone {
+			return fmt.Errorf("no checks reported within %s", timeout)
+		}
+		return fmt.Errorf("checks not completed within %s", timeout)
+	}
+
+	for {
+		statuses, runs, err := getChecks(ctx, owner, repo, sha)
+		if err != nil {
+			if ctx.Err() != nil {
+				return expired()
+			}
+			return err
+		}
+
+		var failed []string
+		state, failed = evaluateChecks(statuses, runs)
+		switch state {
+		case checksPassed:
+			return nil
+		case checksFailed:
+			return fmt.Errorf("checks failed: %s", strings.Join(failed, ", "))
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return expired()
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v60/github"
+	"github.com/kaatinga/robot/internal/pretty"
+)
+
+func Test_evaluateChecks(t *testing.T) {
+	status := func(context, state string) *github.RepoStatus {
+		return &github.RepoStatus{Context: github.String(context), State: github.String(state)}
+	}
+	run := func(name, status, conclusion string) *github.CheckRun {
+		return &github.CheckRun{Name: github.String(name), Status: github.String(status), Conclusion: github.String(conclusion)}
+	}
+
+	tests := []struct {
+		name       string
+		statuses   []*github.RepoStatus
+		runs       []*github.CheckRun
+		want       checksState
+		wantFailed []string
+	}{
+		{name: "nothing reported", want: checksNone},
+		{
+			name:     "all passed",
+			statuses: []*github.RepoStatus{status("ci/circleci", "success")},
+			runs:     []*github.CheckRun{run("test", "completed", "success"), run("lint", "completed", "skipped")},
+			want:     checksPassed,
+		},
+		{
+			name:     "run in progress",
+			statuses: []*github.RepoStatus{status("ci/circleci", "success")},
+			runs:     []*github.CheckRun{run("test", "in_progress", ""), run("lint", "completed", "success")},
+			want:     checksPending,
+		},
+		{
+			name:     "status pending",
+			statuses: []*github.RepoStatus{status("ci/circleci", "pending")},
+			want:     checksPending,
+		},
+		{
+			name:       "failed while others are pending",
+			statuses:   []*github.RepoStatus{status("ci/circleci", "error")},
+			runs:       []*github.CheckRun{run("test", "queued", ""), run("lint", "completed", "failure")},
+			want:       checksFailed,
+			wantFailed: []string{"ci/circleci", "lint"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, failed := evaluateChecks(tt.statuses, tt.runs)
+			if got != tt.want || !slices.Equal(failed, tt.wantFailed) {
+				t.Errorf("evaluateChecks() = %v, %v, want %v, %v", got, failed, tt.want, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func Test_waitForChecks(t *testing.T) {
+	const passed = `{"check_runs": [{"name": "test", "status": "completed", "conclusion": "success"}]}`
+	const none = `{"check_runs": []}`
+
+	tests := []struct {
+		name string
+		// runs are the check runs reported by the polls one by one, the last one being repeated
+		runs    []string
+		wantErr string
+	}{
+		{name: "passed", runs: []string{passed}},
+		{name: "queued at first", runs: []string{none, none, passed}},
+		{name: "nothing reported", runs: []string{none}, wantErr: "no checks reported"},
+		{
+			name:    "failed",
+			runs:    []string{`{"check_runs": [{"name": "lint", "status": "completed", "conclusion": "failure"}]}`},
+			wantErr: "checks failed: lint",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var polls atomic.Int32
+			mux := http.NewServeMux()
+			mux.HandleFunc("/repos/kaatinga/robot/commits/abc/status", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"statuses": []}`)
+			})
+			mux.HandleFunc("/repos/kaatinga/robot/commits/abc/check-runs", func(w http.ResponseWriter, r *http.Request) {
+				poll := int(polls.Add(1)) - 1
+				fmt.Fprint(w, tt.runs[min(poll, len(tt.runs)-1)])
+			})
+			useTestServer(t, mux)
+
+			err := waitForChecks(context.Background(), "kaatinga", "robot", "abc", 200*time.Millisecond, time.Millisecond)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("waitForChecks() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := int(polls.Load()); tt.wantErr == "" && got != len(tt.runs) {
+				t.Errorf("waitForChecks() polled %d times, want %d", got, len(tt.runs))
+			}
+		})
+	}
+}
+
+func Test_workflowUpdate_merge(t *testing.T) {
+	var requests []string
+	useTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/repos/kaatinga/robot/commits/new/status":
+			fmt.Fprint(w, `{"statuses": []}`)
+		case "/repos/kaatinga/robot/commits/new/check-runs":
+			fmt.Fprint(w, `{"check_runs": [{"name": "test", "status": "completed", "conclusion": "success"}]}`)
+		case "/repos/kaatinga/robot/pulls/9/merge":
+			var options struct {
+				SHA string `json:"sha"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&options); err != nil || options.SHA != "new" {
+				t.Errorf("merge of sha %q, want %q", options.SHA, "new")
+			}
+			fmt.Fprint(w, `{"merged": true}`)
+		case "/repos/kaatinga/robot/git/refs/heads/robot-works-2024-01-01T000000Z":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	u := &workflowUpdate{
+		updateWorkflowFilesJob: &updateWorkflowFilesJob{
+			toMerge:        true,
+			mergeMethod:    DefaultMergeMethod,
+			waitForChecks:  true,
+			checksTimeout:  time.Second,
+			checksInterval: time.Millisecond,
+		},
+		owner:   "kaatinga",
+		repo:    "robot",
+		branch:  "robot-works-2024-01-01T000000Z",
+		printer: pretty.NewScopePrinterTo(io.Discard, ""),
+		headSHA: "new",
+	}
+	// the head of the pull request returned before the branch was updated is stale
+	pr := &github.PullRequest{Number: github.Int(9), Head: &github.PullRequestBranch{SHA: github.String("old")}}
+
+	if err := u.merge(context.Background(), pr); err != nil {
+		t.Fatalf("merge() error = %v", err)
+	}
+
+	want := []string{
+		"GET /repos/kaatinga/robot/commits/new/status",
+		"GET /repos/kaatinga/robot/commits/new/check-runs",
+		"PUT /repos/kaatinga/robot/pulls/9/merge",
+		"DELETE /repos/kaatinga/robot/git/refs/heads/robot-works-2024-01-01T000000Z",
+	}
+	if !slices.Equal(requests, want) {
+		t.Errorf("merge() requests = %v, want %v", requests, want)
+	}
+}
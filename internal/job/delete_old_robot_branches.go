@@ -2,58 +2,158 @@ package job
 
 import (
 	"context"
-	"github.com/google/go-github/v60/github"
-	"github.com/kaatinga/robot/internal/pretty"
+	"errors"
+	"fmt"
 	"strings"
+	"time"
+
+	"github.com/google/go-github/v60/github"
 )
 
+// DefaultBranchMinAge is the age a robot branch must reach to be deleted by default.
+const DefaultBranchMinAge = 24 * time.Hour
+
 type deleteOldRobotBranchesJob struct {
-	user string
+	// minAge is the age a robot branch must reach to be deleted, so that the branches of running jobs survive
+	minAge time.Duration
+	// now is the time the ages of the branches are counted to
+	now time.Time
+	// dryRun prints the branches to delete instead of deleting them
+	dryRun bool
 }
 
-func NewDeleteOldRobotBranchesJob(user string) *deleteOldRobotBranchesJob {
+// outcomes of the cleanup job
+const (
+	outcomeBranchesDeleted = "branches deleted"
+	outcomeBranchesPlanned = "branches planned for deletion"
+	outcomeBranchesKept    = "branches kept"
+)
+
+func NewDeleteOldRobotBranchesJob(minAge time.Duration, dryRun bool) *deleteOldRobotBranchesJob {
 	return &deleteOldRobotBranchesJob{
-		user: user,
+		minAge: minAge,
+		now:    time.Now(),
+		dryRun: dryRun,
 	}
 }
 
-func (j *deleteOldRobotBranchesJob) User() string {
-	return j.user
+func (j *deleteOldRobotBranchesJob) Name() string {
+	return "cleanup-branches"
 }
 
-func (j *deleteOldRobotBranchesJob) Next() {
-
+func (j *deleteOldRobotBranchesJob) Outcomes() []string {
+	return []string{outcomeBranchesDeleted, outcomeBranchesPlanned, outcomeBranchesKept}
 }
 
-func (j *deleteOldRobotBranchesJob) Counter() uint16 {
-	return 0
-}
-
-func (j *deleteOldRobotBranchesJob) PRURLs() []string {
-	return nil
-}
+// Run deletes the robot branches older than the minimal age that no open pull request comes from.
+// A failure to delete a branch does not stop the deletion of the others; all the failures are returned together.
+// In a dry run, the branches are only listed.
+func (j *deleteOldRobotBranchesJob) Run(ctx context.Context, repo RepoContext) (result RepoResult, err error) {
+	printer := repo.Printer.WithPrefix("---")
+	owner, name := repo.Owner, repo.Name
 
-func (j *deleteOldRobotBranchesJob) DeleteLeftRobotBranches(ctx context.Context, repo *github.Repository) error {
-	printer := pretty.NewScopePrinter("---")
+	branches, err := listRobotBranches(ctx, owner, name)
+	if err != nil {
+		return result, err
+	}
+	if len(branches) == 0 {
+		printer.Info("No robot branches")
+		return result, nil
+	}
 
-	// get all branches
-	opts := &github.BranchListOptions{ListOptions: github.ListOptions{PerPage: 100}}
-	branches, _, err := client.Repositories.ListBranches(ctx, j.User(), repo.GetName(), opts)
+	openPRs, err := openPRBranches(ctx, owner, name)
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	// delete all branches with the prefix "branchPrefix"
+	var deleted int
+	var errs []error
 	for _, branch := range branches {
-		if strings.Contains(branch.GetName(), branchPrefix) {
-			_, err := client.Git.DeleteRef(ctx, j.User(), repo.GetName(), "heads/"+branch.GetName())
-			if err != nil {
-				return err
+		age, known := branchAge(branch, j.now)
+		switch {
+		case openPRs[branch]:
+			printer.Skipped("Branch '%s' kept: an open pull request comes from it", branch)
+		case !known:
+			printer.Skipped("Branch '%s' kept: its name holds no creation time", branch)
+		case age < j.minAge:
+			printer.Skipped("Branch '%s' kept: created %s ago", branch, age.Round(time.Minute))
+		case j.dryRun:
+			printer.Info("Branch '%s' would be deleted", branch)
+			result.Add(outcomeBranchesPlanned, 1, repo.FullName+": "+branch)
+			continue
+		default:
+			if err = deleteBranch(ctx, owner, name, branch); err != nil {
+				errs = append(errs, fmt.Errorf("error deleting branch '%s': %w", branch, err))
+				continue
+			}
+			printer.OK("Branch '%s' deleted", branch)
+			result.Add(outcomeBranchesDeleted, 1, repo.FullName+": "+branch)
+			deleted++
+			continue
+		}
+		result.Add(outcomeBranchesKept, 1)
+	}
+
+	if !j.dryRun {
+		printer.Info("%d of %d robot branches deleted", deleted, len(branches))
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// listRobotBranches returns the names of all the robot branches of the repository.
+func listRobotBranches(ctx context.Context, owner, repo string) ([]string, error) {
+	var branches []string
+	opts := &github.BranchListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.Repositories.ListBranches(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error listing branches: %w", err)
+		}
+
+		for _, branch := range page {
+			if strings.HasPrefix(branch.GetName(), branchPrefix) {
+				branches = append(branches, branch.GetName())
 			}
+		}
 
-			printer.OK("Branch '%s' deleted", branch.GetName())
+		if resp.NextPage == 0 {
+			return branches, nil
 		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// openPRBranches returns the branches of the repository open pull requests come from.
+func openPRBranches(ctx context.Context, owner, repo string) (map[string]bool, error) {
+	branches := make(map[string]bool)
+	opts := &github.PullRequestListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		prs, resp, err := client.PullRequests.List(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error listing pull requests: %w", err)
+		}
+
+		for _, pr := range prs {
+			if pr.GetHead().GetRepo().GetID() == pr.GetBase().GetRepo().GetID() {
+				branches[pr.GetHead().GetRef()] = true
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return branches, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// branchAge returns the age of the robot branch by the creation time in its name.
+// It reports false if the name holds no creation time.
+func branchAge(branch string, now time.Time) (time.Duration, bool) {
+	created, known := branchCreated(branch)
+	if !known {
+		return 0, false
 	}
 
-	return nil
+	return now.Sub(created), true
 }
@@ -0,0 +1,105 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kaatinga/robot/internal/pretty"
+)
+
+func Test_branchAge(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		branch    string
+		want      time.Duration
+		wantKnown bool
+	}{
+		{name: "UTC", branch: branchPrefix + "2024-03-09T120000Z", want: 24 * time.Hour, wantKnown: true},
+		{name: "time zone", branch: branchPrefix + "2024-03-10T150000+0300", want: 0, wantKnown: true},
+		{name: "round trip", branch: branchPrefix + now.Add(-time.Hour).Format(branchSafeTimeFormat), want: time.Hour, wantKnown: true},
+		{name: "no time", branch: branchPrefix + "manual"},
+		{name: "badges", branch: badgesBranchPrefix + "2024-03-09T120000Z", want: 24 * time.Hour, wantKnown: true},
+		{name: "badges without time", branch: badgesBranchPrefix + "manual"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, known := branchAge(tt.branch, now)
+			if got != tt.want || known != tt.wantKnown {
+				t.Errorf("branchAge() = %v, %v, want %v, %v", got, known, tt.want, tt.wantKnown)
+			}
+		})
+	}
+}
+
+func Test_deleteOldRobotBranchesJob_Run(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	old, young, withPR := branchPrefix+"2024-03-01T120000Z", branchPrefix+"2024-03-10T110000Z", branchPrefix+"2024-03-02T120000Z"
+
+	tests := []struct {
+		name        string
+		dryRun      bool
+		wantDeleted []string
+		wantResult  []Outcome
+	}{
+		{
+			name:        "deleted",
+			wantDeleted: []string{"/repos/kaatinga/robot/git/refs/heads/" + old},
+			wantResult:  []Outcome{{Name: outcomeBranchesDeleted, Count: 1, Items: []string{"kaatinga/robot: " + old}}, {Name: outcomeBranchesKept, Count: 3}},
+		},
+		{
+			name:       "dry run",
+			dryRun:     true,
+			wantResult: []Outcome{{Name: outcomeBranchesPlanned, Count: 1, Items: []string{"kaatinga/robot: " + old}}, {Name: outcomeBranchesKept, Count: 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted []string
+			mux := http.NewServeMux()
+			mux.HandleFunc("/repos/kaatinga/robot/branches", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `[{"name": "main"}, {"name": %q}, {"name": %q}, {"name": %q}, {"name": %q}]`, old, young, withPR, branchPrefix+"manual")
+			})
+			mux.HandleFunc("/repos/kaatinga/robot/pulls", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `[{"head": {"ref": %q, "repo": {"id": 1}}, "base": {"repo": {"id": 1}}}]`, withPR)
+			})
+			mux.HandleFunc("/repos/kaatinga/robot/git/refs/", func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodDelete {
+					deleted = append(deleted, r.URL.Path)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			})
+			useTestServer(t, mux)
+
+			j := NewDeleteOldRobotBranchesJob(DefaultBranchMinAge, tt.dryRun)
+			j.now = now
+			result, err := j.Run(context.Background(), RepoContext{
+				Owner:    "kaatinga",
+				Name:     "robot",
+				FullName: "kaatinga/robot",
+				Printer:  pretty.NewScopePrinterTo(io.Discard, ""),
+			})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if !slices.Equal(deleted, tt.wantDeleted) {
+				t.Errorf("Run() deleted %v, want %v", deleted, tt.wantDeleted)
+			}
+			if len(result.Outcomes) != len(tt.wantResult) {
+				t.Fatalf("Run() = %v, want %v", result.Outcomes, tt.wantResult)
+			}
+			for i, want := range tt.wantResult {
+				if got := result.Outcomes[i]; got.Name != want.Name || got.Count != want.Count || !slices.Equal(got.Items, want.Items) {
+					t.Errorf("Run()[%d] = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
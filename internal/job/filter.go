@@ -0,0 +1,121 @@
+package job
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v60/github"
+)
+
+// Filter selects the repositories the jobs are run for. The zero Filter selects all repositories.
+type Filter struct {
+	// Include holds glob patterns, one of which the repository name must match.
+	Include []string
+	// Exclude holds glob patterns of the repository names to skip.
+	Exclude []string
+	// Match is a regular expression the repository name must match.
+	Match *regexp.Regexp
+	// Topics holds topics, one of which the repository must have.
+	Topics []string
+	// Visibility is either "public" or "private". Repositories of any visibility are selected if empty.
+	Visibility string
+	// PushedAfter skips the repositories that have not been pushed to since the given time.
+	PushedAfter time.Time
+	// Languages holds the languages, one of which must be the primary language of the repository.
+	Languages []string
+	// Deny holds the names or the full names (owner/name) of the repositories that are never processed.
+	Deny []string
+}
+
+// Validate checks the patterns and the visibility of the filter.
+func (f Filter) Validate() error {
+	for _, pattern := range append(f.Include, f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid repository pattern '%s': %w", pattern, err)
+		}
+	}
+
+	switch f.Visibility {
+	case "", "public", "private":
+	default:
+		return fmt.Errorf("invalid visibility '%s': must be 'public' or 'private'", f.Visibility)
+	}
+
+	return nil
+}
+
+// skipReason returns the reason the repository is filtered out, or an empty string if the repository is selected.
+func (f Filter) skipReason(repo *github.Repository) string {
+	name := repo.GetName()
+
+	for _, denied := range f.Deny {
+		if strings.EqualFold(denied, name) || strings.EqualFold(denied, repo.GetFullName()) {
+			return "Denied"
+		}
+	}
+
+	if len(f.Include) != 0 && !matchAny(f.Include, name) {
+		return "Does not match the included patterns"
+	}
+
+	if matchAny(f.Exclude, name) {
+		return "Matches the excluded patterns"
+	}
+
+	if f.Match != nil && !f.Match.MatchString(name) {
+		return fmt.Sprintf("Does not match '%s'", f.Match)
+	}
+
+	if len(f.Topics) != 0 && !containsAny(f.Topics, repo.Topics) {
+		return "Has none of the topics: " + strings.Join(f.Topics, ", ")
+	}
+
+	if f.Visibility != "" && f.Visibility != repoVisibility(repo) {
+		return "Not " + f.Visibility
+	}
+
+	if !f.PushedAfter.IsZero() && repo.GetPushedAt().Before(f.PushedAfter) {
+		return "Last pushed " + repo.GetPushedAt().Format(time.DateOnly)
+	}
+
+	if len(f.Languages) != 0 && !containsAny(f.Languages, []string{repo.GetLanguage()}) {
+		return "Written in " + repo.GetLanguage()
+	}
+
+	return ""
+}
+
+// repoVisibility returns "public" or "private". Internal repositories are considered private.
+func repoVisibility(repo *github.Repository) string {
+	if repo.GetPrivate() {
+		return "private"
+	}
+
+	return "public"
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsAny reports whether the values have at least one of the wanted items, ignoring case.
+func containsAny(wanted, values []string) bool {
+	for _, w := range wanted {
+		for _, v := range values {
+			if strings.EqualFold(w, v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,51 @@
+package job
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v60/github"
+)
+
+func TestFilter_skipReason(t *testing.T) {
+	repo := &github.Repository{
+		Name:     github.String("settings"),
+		FullName: github.String("kaatinga/settings"),
+		Topics:   []string{"go", "library"},
+		Private:  github.Bool(false),
+		PushedAt: &github.Timestamp{Time: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		Language: github.String("Go"),
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		selected bool
+	}{
+		{"zero filter", Filter{}, true},
+		{"included", Filter{Include: []string{"set*"}}, true},
+		{"not included", Filter{Include: []string{"robot"}}, false},
+		{"excluded", Filter{Exclude: []string{"*ings"}}, false},
+		{"regexp matched", Filter{Match: regexp.MustCompile(`^s`)}, true},
+		{"regexp not matched", Filter{Match: regexp.MustCompile(`^r`)}, false},
+		{"topic", Filter{Topics: []string{"service", "Library"}}, true},
+		{"no topic", Filter{Topics: []string{"service"}}, false},
+		{"public", Filter{Visibility: "public"}, true},
+		{"private", Filter{Visibility: "private"}, false},
+		{"pushed recently", Filter{PushedAfter: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}, true},
+		{"pushed long ago", Filter{PushedAfter: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}, false},
+		{"language", Filter{Languages: []string{"go"}}, true},
+		{"other language", Filter{Languages: []string{"Rust"}}, false},
+		{"denied by name", Filter{Deny: []string{"settings"}}, false},
+		{"denied by full name", Filter{Deny: []string{"kaatinga/settings"}}, false},
+		{"other repo denied", Filter{Deny: []string{"other/settings"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reason := tt.filter.skipReason(repo); (reason == "") != tt.selected {
+				t.Errorf("skipReason() = %q, want selected: %v", reason, tt.selected)
+			}
+		})
+	}
+}
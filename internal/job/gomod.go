@@ -0,0 +1,148 @@
+package job
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GoMod holds the directives of a go.mod file the robot is interested in.
+type GoMod struct {
+	// Module is the module path.
+	Module string
+	// Go is the version given in the go directive, e.g. "1.21" or "1.21.0". It is empty if there is no directive.
+	Go string
+	// Toolchain is the version given in the toolchain directive without the "go" prefix, e.g. "1.22.3".
+	// It is empty if there is no directive.
+	Toolchain string
+}
+
+// parseGoMod extracts the module path, the go and the toolchain directives from the content of a go.mod file.
+func parseGoMod(content []byte) (GoMod, error) {
+	var mod GoMod
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "module":
+			mod.Module = strings.Trim(fields[1], `"`+"`")
+		case "go":
+			mod.Go = fields[1]
+		case "toolchain":
+			mod.Toolchain = strings.TrimPrefix(fields[1], "go")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return GoMod{}, err
+	}
+
+	if mod.Module == "" {
+		return GoMod{}, fmt.Errorf("no module directive found")
+	}
+
+	return mod, nil
+}
+
+// goVersion is a Go release version reduced to its major and minor numbers.
+type goVersion struct {
+	major, minor int
+}
+
+// parseGoVersion parses versions like "1.21", "1.21.3", "1.22rc1" or "go1.23.0".
+func parseGoVersion(version string) (goVersion, bool) {
+	major, rest, found := strings.Cut(strings.TrimPrefix(version, "go"), ".")
+	if !found {
+		return goVersion{}, false
+	}
+
+	// the minor number ends with a patch number or a pre-release suffix
+	end := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+	if end == -1 {
+		end = len(rest)
+	}
+
+	majorNumber, majorErr := strconv.Atoi(major)
+	minorNumber, minorErr := strconv.Atoi(rest[:end])
+	if majorErr != nil || minorErr != nil {
+		return goVersion{}, false
+	}
+
+	return goVersion{majorNumber, minorNumber}, true
+}
+
+func (v goVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.major, v.minor)
+}
+
+// goVersionMatrix returns the minor Go versions from the minimal version up to the latest one,
+// e.g. ["1.21", "1.22", "1.23"] for "1.21.0" and "1.23.2". Unparsable versions are left out.
+func goVersionMatrix(minimal, latest string) []string {
+	from, fromFound := parseGoVersion(minimal)
+	to, toFound := parseGoVersion(latest)
+	switch {
+	case !fromFound && !toFound:
+		return nil
+	case !fromFound:
+		return []string{to.String()}
+	case !toFound || to.major != from.major || to.minor < from.minor:
+		return []string{from.String()}
+	}
+
+	matrix := make([]string, 0, to.minor-from.minor+1)
+	for minor := from.minor; minor <= to.minor; minor++ {
+		matrix = append(matrix, goVersion{from.major, minor}.String())
+	}
+
+	return matrix
+}
+
+// goReleasesURL lists the current Go releases.
+const goReleasesURL = "https://go.dev/dl/?mode=json"
+
+// LatestGoVersion returns the latest stable Go version, e.g. "1.23.2".
+func LatestGoVersion(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, goReleasesURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error getting Go releases: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error getting Go releases: %s", resp.Status)
+	}
+
+	var releases []struct {
+		Version string `json:"version"`
+		Stable  bool   `json:"stable"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return "", fmt.Errorf("error decoding Go releases: %w", err)
+	}
+
+	for _, release := range releases {
+		if release.Stable {
+			return strings.TrimPrefix(release.Version, "go"), nil
+		}
+	}
+
+	return "", fmt.Errorf("no stable Go release found")
+}
@@ -0,0 +1,77 @@
+package job
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_parseGoMod(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    GoMod
+		wantErr bool
+	}{
+		{
+			name:    "module and go",
+			content: "module github.com/kaatinga/robot\n\ngo 1.21\n\nrequire (\n\tgolang.org/x/oauth2 v0.21.0\n)\n",
+			want:    GoMod{Module: "github.com/kaatinga/robot", Go: "1.21"},
+		},
+		{
+			name:    "quoted module with comments",
+			content: "// the robot\nmodule \"github.com/kaatinga/robot\" // deprecated\ngo 1.22.3\n",
+			want:    GoMod{Module: "github.com/kaatinga/robot", Go: "1.22.3"},
+		},
+		{
+			name:    "toolchain",
+			content: "module example.com/new\n\ngo 1.21.0\n\ntoolchain go1.22.3\n",
+			want:    GoMod{Module: "example.com/new", Go: "1.21.0", Toolchain: "1.22.3"},
+		},
+		{
+			name:    "no go directive",
+			content: "module example.com/old\n",
+			want:    GoMod{Module: "example.com/old"},
+		},
+		{
+			name:    "no module directive",
+			content: "go 1.21\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGoMod([]byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseGoMod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseGoMod() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_goVersionMatrix(t *testing.T) {
+	tests := []struct {
+		name    string
+		minimal string
+		latest  string
+		want    []string
+	}{
+		{name: "range", minimal: "1.21.0", latest: "1.23.2", want: []string{"1.21", "1.22", "1.23"}},
+		{name: "same minor", minimal: "1.22", latest: "1.22.5", want: []string{"1.22"}},
+		{name: "pre-release", minimal: "1.22rc1", latest: "go1.23.0", want: []string{"1.22", "1.23"}},
+		{name: "unknown latest", minimal: "1.21", latest: "", want: []string{"1.21"}},
+		{name: "no go directive", minimal: "", latest: "1.23.2", want: []string{"1.23"}},
+		{name: "minimal above latest", minimal: "1.24", latest: "1.23.2", want: []string{"1.24"}},
+		{name: "nothing known", minimal: "", latest: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := goVersionMatrix(tt.minimal, tt.latest)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("goVersionMatrix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
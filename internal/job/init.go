@@ -2,10 +2,17 @@ package job
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/google/go-github/v60/github"
 	"golang.org/x/oauth2"
 
+	"github.com/kaatinga/robot/internal/pretty"
+	"github.com/kaatinga/robot/internal/ratelimit"
+	"github.com/kaatinga/robot/internal/retry"
 	"github.com/kaatinga/robot/internal/tool"
 )
 
@@ -15,8 +22,28 @@ func Init() {
 			&oauth2.Token{AccessToken: tool.GetOptions().GitHubToken},
 		)
 
-		ctx := context.Background()
-		tc := oauth2.NewClient(ctx, ts)
+		waitPrinter := pretty.NewScopePrinterTo(os.Stderr, "")
+		tc := &http.Client{
+			Transport: &retry.Transport{
+				Base: &ratelimit.Transport{
+					Base:    &oauth2.Transport{Source: ts},
+					Reserve: tool.GetOptions().RateLimitReserve,
+					Notify: func(wait time.Duration, reason string) {
+						waitPrinter.Info("Rate limit: waiting %s (%s)", wait.Round(time.Second), reason)
+					},
+				},
+			},
+		}
 		client = github.NewClient(tc)
 	})
 }
+
+// AuthenticatedUser returns the login of the user the GitHub token belongs to.
+func AuthenticatedUser(ctx context.Context) (string, error) {
+	user, _, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return "", fmt.Errorf("error getting authenticated user: %w", err)
+	}
+
+	return user.GetLogin(), nil
+}
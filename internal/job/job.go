@@ -1,8 +1,129 @@
 package job
 
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v60/github"
+	"github.com/kaatinga/robot/internal/pretty"
+)
+
+// Job is run by FetchAllGoRepos in every selected Go repository, possibly in several repositories at once.
+// A job keeps no state of its own between the repositories: everything it needs is in the RepoContext, and
+// everything it did is returned in the RepoResult.
 type Job interface {
-	User() string
-	Next()
-	PRURLs() []string
-	Counter() uint16
+	// Name is shown in the heading of the summary.
+	Name() string
+	// Outcomes declares the outcomes the job reports, in the order they are summarized in.
+	Outcomes() []string
+	// Run processes the repository. The result is counted in the summary even if an error is returned.
+	Run(ctx context.Context, repo RepoContext) (RepoResult, error)
+}
+
+// RepoContext describes the repository a job runs in. It is passed by value and never changed by the jobs.
+type RepoContext struct {
+	Owner         string
+	Name          string
+	FullName      string
+	DefaultBranch string
+	// Visibility is public, private or internal.
+	Visibility string
+	PushedAt   time.Time
+	// Mod is the go.mod file in the root of the repository.
+	Mod GoMod
+	// Printer prints to the output of the repository.
+	Printer pretty.ScopePrinter
+
+	topics []string
+}
+
+// newRepoContext copies the details of the repository the jobs use.
+func newRepoContext(repo *github.Repository, mod GoMod, printer pretty.ScopePrinter) RepoContext {
+	return RepoContext{
+		Owner:         repo.GetOwner().GetLogin(),
+		Name:          repo.GetName(),
+		FullName:      repo.GetFullName(),
+		DefaultBranch: repo.GetDefaultBranch(),
+		Visibility:    repo.GetVisibility(),
+		PushedAt:      repo.GetPushedAt().Time,
+		Mod:           mod,
+		Printer:       printer,
+		topics:        slices.Clone(repo.Topics),
+	}
+}
+
+// Topics returns a copy of the topics of the repository.
+func (r RepoContext) Topics() []string {
+	return slices.Clone(r.topics)
+}
+
+// Outcome is a kind of result of a job, e.g. pull requests created or branches deleted, counted over the repositories.
+type Outcome struct {
+	// Name describes the outcome in the summary, e.g. "pull requests created".
+	Name  string
+	Count int
+	// Items lists the things the outcome is about, e.g. the URLs of the pull requests. It may be empty.
+	Items []string
+}
+
+// RepoResult is what a job did in a repository.
+type RepoResult struct {
+	Outcomes []Outcome
+}
+
+// Add counts the outcome n times, listing the items.
+func (r *RepoResult) Add(name string, n int, items ...string) {
+	for i := range r.Outcomes {
+		if r.Outcomes[i].Name == name {
+			r.Outcomes[i].Count += n
+			r.Outcomes[i].Items = append(r.Outcomes[i].Items, items...)
+			return
+		}
+	}
+
+	r.Outcomes = append(r.Outcomes, Outcome{Name: name, Count: n, Items: items})
+}
+
+// outcomes sums up the results of a job over the repositories. It is safe for concurrent use.
+type outcomes struct {
+	mu       sync.Mutex
+	outcomes []Outcome
+}
+
+// newOutcomes declares the outcomes of a job in the order they are reported in.
+func newOutcomes(names ...string) *outcomes {
+	o := &outcomes{outcomes: make([]Outcome, len(names))}
+	for i, name := range names {
+		o.outcomes[i].Name = name
+	}
+
+	return o
+}
+
+// add counts the outcomes of the repository. Outcomes not declared are added after the declared ones.
+func (o *outcomes) add(result RepoResult) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	summary := RepoResult{Outcomes: o.outcomes}
+	for _, outcome := range result.Outcomes {
+		summary.Add(outcome.Name, outcome.Count, outcome.Items...)
+	}
+	o.outcomes = summary.Outcomes
+}
+
+// Outcomes returns a copy of the counted outcomes.
+func (o *outcomes) Outcomes() []Outcome {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	copied := make([]Outcome, len(o.outcomes))
+	for i, outcome := range o.outcomes {
+		copied[i] = outcome
+		copied[i].Items = slices.Clone(outcome.Items)
+	}
+
+	return copied
 }
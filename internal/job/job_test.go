@@ -0,0 +1,96 @@
+package job
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v60/github"
+	"github.com/kaatinga/robot/internal/pretty"
+)
+
+func Test_outcomes(t *testing.T) {
+	o := newOutcomes("created", "deleted", "kept")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var result RepoResult
+			result.Add("kept", 1)
+			o.add(result)
+		}()
+	}
+	wg.Wait()
+
+	var result RepoResult
+	result.Add("created", 1, "a")
+	result.Add("unknown", 1)
+	result.Add("created", 1, "b")
+	o.add(result)
+
+	got := o.Outcomes()
+	want := []Outcome{
+		{Name: "created", Count: 2, Items: []string{"a", "b"}},
+		{Name: "deleted"},
+		{Name: "kept", Count: 10},
+		{Name: "unknown", Count: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Outcomes() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || got[i].Count != want[i].Count || !slices.Equal(got[i].Items, want[i].Items) {
+			t.Errorf("Outcomes()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	got[0].Items[0] = "changed"
+	if o.Outcomes()[0].Items[0] != "a" {
+		t.Error("Outcomes() returned the items of the job")
+	}
+}
+
+func Test_newRepoContext(t *testing.T) {
+	repo := &github.Repository{
+		Name:          github.String("robot"),
+		FullName:      github.String("kaatinga/robot"),
+		Owner:         &github.User{Login: github.String("kaatinga")},
+		DefaultBranch: github.String("main"),
+		Topics:        []string{"go"},
+	}
+	rc := newRepoContext(repo, GoMod{Module: "github.com/kaatinga/robot"}, pretty.NewScopePrinter(""))
+
+	if rc.Owner != "kaatinga" || rc.Name != "robot" || rc.FullName != "kaatinga/robot" || rc.DefaultBranch != "main" || rc.Mod.Module != "github.com/kaatinga/robot" {
+		t.Errorf("newRepoContext() = %+v", rc)
+	}
+
+	repo.Topics[0] = "changed"
+	topics := rc.Topics()
+	topics[0] = "changed too"
+	if rc.Topics()[0] != "go" {
+		t.Errorf("Topics() = %v, want the topics at the creation of the context", rc.Topics())
+	}
+}
+
+// useTestServer points the client to a test server handling the API requests until the end of the test.
+func useTestServer(t *testing.T, handler http.Handler) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	baseURL, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := client
+	client = github.NewClient(server.Client())
+	client.BaseURL = baseURL
+	t.Cleanup(func() {
+		client = previous
+		server.Close()
+	})
+}
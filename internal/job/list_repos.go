@@ -0,0 +1,36 @@
+package job
+
+import (
+	"context"
+	"time"
+)
+
+type listReposJob struct{}
+
+const outcomeReposListed = "repositories listed"
+
+func NewListReposJob() *listReposJob {
+	return &listReposJob{}
+}
+
+func (j *listReposJob) Name() string {
+	return "list-repos"
+}
+
+func (j *listReposJob) Outcomes() []string {
+	return []string{outcomeReposListed}
+}
+
+// Run prints the details of a repository the robot would process.
+func (j *listReposJob) Run(_ context.Context, repo RepoContext) (result RepoResult, err error) {
+	printer := repo.Printer.WithPrefix("---")
+	printer.OK("%s (default branch '%s', %s, pushed %s)",
+		repo.FullName,
+		repo.DefaultBranch,
+		repo.Visibility,
+		repo.PushedAt.Format(time.DateOnly),
+	)
+	result.Add(outcomeReposListed, 1)
+
+	return result, nil
+}
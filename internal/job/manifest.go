@@ -0,0 +1,71 @@
+package job
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v60/github"
+)
+
+// manifestPath is the file listing the files the robot manages in a repository.
+const manifestPath = ".github/.robot-managed"
+
+const manifestHeader = `# Files managed by the robot. A listed file is deleted once its template is removed.
+# Files not listed here are never deleted by the robot.
+`
+
+// manifest holds the repository paths of the files the robot manages.
+type manifest map[string]bool
+
+// parseManifest reads the paths listed one per line, skipping the empty lines and the comments.
+func parseManifest(content []byte) manifest {
+	managed := make(manifest)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			managed[line] = true
+		}
+	}
+
+	return managed
+}
+
+// format returns the content of the manifest file with the paths sorted.
+func (m manifest) format() []byte {
+	var content bytes.Buffer
+	content.WriteString(manifestHeader)
+	for _, filePath := range sortedKeys(m) {
+		content.WriteString(filePath)
+		content.WriteString("\n")
+	}
+
+	return content.Bytes()
+}
+
+// getManifest returns the manifest of the repository and the blob SHA of the manifest file.
+// The manifest is empty and the SHA is empty if the repository has no manifest.
+func getManifest(ctx context.Context, owner, repo, ref string) (manifest, string, error) {
+	file, _, resp, err := client.Repositories.GetContents(ctx, owner, repo, manifestPath, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return manifest{}, "", nil
+		}
+		return nil, "", fmt.Errorf("error getting %s: %w", manifestPath, err)
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, "", fmt.Errorf("error decoding %s: %w", manifestPath, err)
+	}
+
+	return parseManifest([]byte(content)), file.GetSHA(), nil
+}
+
+// keeps reports whether the file matches one of the glob patterns, given either for the repository path or for the file name.
+func keeps(patterns []string, filePath string) bool {
+	return matchAny(patterns, filePath) || matchAny(patterns, path.Base(filePath))
+}
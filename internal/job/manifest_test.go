@@ -0,0 +1,188 @@
+package job
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kaatinga/robot/internal/pretty"
+)
+
+func Test_manifest(t *testing.T) {
+	managed := manifest{".github/workflows/test.yml": true, ".github/workflows/golangci-lint.yml": true}
+	content := managed.format()
+
+	want := manifestHeader + ".github/workflows/golangci-lint.yml\n.github/workflows/test.yml\n"
+	if string(content) != want {
+		t.Errorf("format() = %q, want %q", content, want)
+	}
+
+	parsed := parseManifest(append(content, "\n  # comment\n  .github/dependabot.yml  \n"...))
+	if len(parsed) != 3 || !parsed[".github/workflows/test.yml"] || !parsed[".github/dependabot.yml"] {
+		t.Errorf("parseManifest() = %v", parsed)
+	}
+}
+
+func Test_keeps(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		filePath string
+		want     bool
+	}{
+		{name: "file name", patterns: []string{"release.yml"}, filePath: ".github/workflows/release.yml", want: true},
+		{name: "name pattern", patterns: []string{"codeql*"}, filePath: ".github/workflows/codeql-analysis.yml", want: true},
+		{name: "path pattern", patterns: []string{".github/workflows/*.yaml"}, filePath: ".github/workflows/deploy.yaml", want: true},
+		{name: "other directory", patterns: []string{".github/*.yml"}, filePath: ".github/workflows/test.yml", want: false},
+		{name: "no patterns", filePath: ".github/workflows/test.yml", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keeps(tt.patterns, tt.filePath); got != tt.want {
+				t.Errorf("keeps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_workflowUpdate_templateChanges(t *testing.T) {
+	u := &workflowUpdate{
+		updateWorkflowFilesJob: &updateWorkflowFilesJob{keep: []string{"release.yml"}},
+		override:               RepoOverride{Keep: []string{"codeql.yml"}},
+		printer:                pretty.NewScopePrinterTo(io.Discard, ""),
+		filesToUpdate: map[string][]byte{
+			".github/workflows/test.yml":    []byte("test"),
+			".github/workflows/lint.yml":    []byte("new lint"),
+			".github/workflows/build.yml":   []byte("build"),
+			".github/workflows/release.yml": []byte("release"),
+		},
+	}
+	files := map[string]string{
+		".github/workflows/test.yml":    blobSHA([]byte("test")),
+		".github/workflows/lint.yml":    blobSHA([]byte("old lint")),
+		".github/workflows/release.yml": blobSHA([]byte("own release")),
+		".github/workflows/old.yml":     blobSHA([]byte("old")),
+		".github/workflows/codeql.yml":  blobSHA([]byte("codeql")),
+		".github/workflows/own.yml":     blobSHA([]byte("own")),
+	}
+	managed := manifest{
+		".github/workflows/test.yml":   true,
+		".github/workflows/lint.yml":   true,
+		".github/workflows/old.yml":    true,
+		".github/workflows/codeql.yml": true,
+		".github/workflows/gone.yml":   true,
+	}
+
+	changes, newManifest, result := u.templateChanges(files, managed)
+
+	var got []string
+	for _, change := range changes {
+		got = append(got, change.result().String()+" "+change.path)
+	}
+	// the kept files and the files the robot does not manage are left untouched
+	want := []string{
+		"Created .github/workflows/build.yml",
+		"Updated .github/workflows/lint.yml",
+		"Deleted .github/workflows/old.yml",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("templateChanges() changes = %v, want %v", got, want)
+	}
+	if wantManifest := []string{".github/workflows/build.yml", ".github/workflows/lint.yml", ".github/workflows/test.yml"}; !slices.Equal(sortedKeys(newManifest), wantManifest) {
+		t.Errorf("templateChanges() manifest = %v, want %v", sortedKeys(newManifest), wantManifest)
+	}
+	if result != resultSkipped {
+		t.Errorf("templateChanges() result = %v, want %v", result, resultSkipped)
+	}
+}
+
+func Test_updateWorkflowFilesJob_Run_manifest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".github/workflows"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".github/workflows/test.yml"), []byte("test"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	managed := manifest{".github/workflows/test.yml": true}
+
+	tests := []struct {
+		name         string
+		manifest     string
+		wantRequests []string
+	}{
+		{
+			name: "files in sync without manifest",
+			wantRequests: []string{
+				"POST /repos/kaatinga/robot/git/trees",
+				"POST /repos/kaatinga/robot/git/commits",
+				"POST /repos/kaatinga/robot/git/refs",
+				"POST /repos/kaatinga/robot/pulls",
+			},
+		},
+		{name: "manifest up to date", manifest: string(managed.format())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			useTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet {
+					requests = append(requests, r.Method+" "+r.URL.Path)
+				}
+
+				switch path := strings.TrimPrefix(r.URL.Path, "/repos/kaatinga/robot/"); {
+				case path == "git/ref/heads/main":
+					fmt.Fprint(w, `{"ref": "refs/heads/main", "object": {"sha": "base"}}`)
+				case path == "git/trees/base":
+					fmt.Fprintf(w, `{"sha": "base-tree", "tree": [{"path": ".github/workflows/test.yml", "type": "blob", "mode": "100644", "sha": %q}]}`, blobSHA([]byte("test")))
+				case path == "contents/.github/.robot-managed" && tt.manifest == "":
+					w.WriteHeader(http.StatusNotFound)
+				case path == "contents/.github/.robot-managed":
+					fmt.Fprintf(w, `{"type": "file", "encoding": "base64", "sha": %q, "content": %q}`,
+						blobSHA([]byte(tt.manifest)), base64.StdEncoding.EncodeToString([]byte(tt.manifest)))
+				case path == "pulls" && r.Method == http.MethodGet:
+					fmt.Fprint(w, "[]")
+				case path == "pulls":
+					fmt.Fprint(w, `{"number": 9}`)
+				case path == "git/commits/base":
+					fmt.Fprint(w, `{"sha": "base", "tree": {"sha": "base-tree"}}`)
+				case path == "git/trees":
+					fmt.Fprint(w, `{"sha": "new-tree"}`)
+				case path == "git/commits":
+					fmt.Fprint(w, `{"sha": "new"}`)
+				case path == "git/refs":
+					fmt.Fprint(w, `{"ref": "refs/heads/new"}`)
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+
+			j, err := NewUpdateWorkflowJob(UpdateWorkflowOptions{Templates: TemplateOptions{Dir: dir, NoEmbedded: true}})
+			if err != nil {
+				t.Fatalf("NewUpdateWorkflowJob() error = %v", err)
+			}
+			_, err = j.Run(context.Background(), RepoContext{
+				Owner:         "kaatinga",
+				Name:          "robot",
+				FullName:      "kaatinga/robot",
+				DefaultBranch: "main",
+				Printer:       pretty.NewScopePrinterTo(io.Discard, ""),
+			})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if !slices.Equal(requests, tt.wantRequests) {
+				t.Errorf("Run() requests = %v, want %v", requests, tt.wantRequests)
+			}
+		})
+	}
+}
@@ -3,8 +3,9 @@ package job
 import (
 	"fmt"
 	"io/fs"
-	"os"
-	"path/filepath"
+	"path"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/google/go-github/v60/github"
@@ -40,24 +41,40 @@ const (
 	createAction
 )
 
-// loadTemplates loads all templates from templates directory. Returns a map of template name to template content.
-func loadTemplates() (map[string][]byte, error) {
-	var templates = make(map[string][]byte)
-	// list all files in the `templates` directory
-	err := filepath.WalkDir("templates", func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
+// templateSuffix marks the templates rendered with text/template. It is left out of the paths in the repositories.
+// The other files are copied as they are.
+const templateSuffix = ".tmpl"
+
+// templateFile is the content of a template and the place it was loaded from.
+type templateFile struct {
+	content []byte
+	// origin is the path of the file on disk or in the embedded templates
+	origin string
+	// render reports whether the content is rendered with text/template or copied as it is
+	render bool
+}
+
+// loadTemplates loads all templates from the file system. Returns a map of template path to template file.
+// The template paths are the paths of the files in the repositories, as the file system mirrors the repository layout,
+// without the template suffix. The origin of every template is its path in the file system joined to the given origin.
+func loadTemplates(fsys fs.FS, origin string) (map[string]templateFile, error) {
+	var templates = make(map[string]templateFile)
+	// list all files in the file system
+	err := fs.WalkDir(fsys, ".", func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
 			return err
 		}
 
-		bytes, err := os.ReadFile(path)
+		bytes, err := fs.ReadFile(fsys, filePath)
 		if err != nil {
-			if d.IsDir() {
-				return nil
-			}
 			return err
 		}
 
-		templates[d.Name()] = bytes
+		target := strings.TrimSuffix(filePath, templateSuffix)
+		if _, found := templates[target]; found {
+			return fmt.Errorf("both %s and %s are written to %s", target, target+templateSuffix, target)
+		}
+		templates[target] = templateFile{content: bytes, origin: path.Join(origin, filePath), render: target != filePath}
 		return nil
 	})
 	if err != nil {
@@ -67,6 +84,17 @@ func loadTemplates() (map[string][]byte, error) {
 	return templates, nil
 }
 
+// sortedKeys returns the keys of the map in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
 // GenerateRandomString generates a random string of a specified length.
 //func GenerateRandomString(n int) ([]byte, error) {
 //	bytes := make([]byte, n)
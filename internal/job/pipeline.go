@@ -0,0 +1,111 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// fileChanger is a job changing files of the repository. In a pipeline sharing the pull request, its changes are
+// committed by the update job along with the changes of the templates, to the branch of a single pull request.
+type fileChanger interface {
+	Job
+	// fileChanges returns the changes the repository needs on top of the base branch.
+	fileChanges(ctx context.Context, repo RepoContext, base baseState) ([]fileChange, error)
+}
+
+// baseState describes the base branch the changes of the files are made on.
+type baseState struct {
+	branch string
+	// files holds the blob SHAs of the files at the head of the branch, keyed by their paths
+	files map[string]string
+	// managed lists the files the robot manages once the changes are merged
+	managed manifest
+	// rendered holds the contents of the managed files rendered from the templates for the same pull request
+	rendered map[string][]byte
+}
+
+// pipeline runs several jobs in every repository, one after another.
+type pipeline struct {
+	jobs []Job
+}
+
+// NewPipeline returns a job running the jobs in the order given. If sharePullRequest is set, the changes of the jobs
+// changing files are committed by the update job to its pull request instead of to pull requests of their own.
+func NewPipeline(jobs []Job, sharePullRequest bool) (Job, error) {
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no jobs in the pipeline")
+	}
+	if !sharePullRequest {
+		return &pipeline{jobs: jobs}, nil
+	}
+
+	updateAt := slices.IndexFunc(jobs, func(j Job) bool {
+		_, ok := j.(*updateWorkflowFilesJob)
+		return ok
+	})
+	if updateAt < 0 {
+		return nil, fmt.Errorf("sharing a pull request requires the update-workflows job")
+	}
+
+	// the update job takes over the changes of the other jobs changing files
+	update := *jobs[updateAt].(*updateWorkflowFilesJob)
+	update.changers = slices.Clone(update.changers)
+	var shared []Job
+	for i, j := range jobs {
+		changer, ok := j.(fileChanger)
+		switch {
+		case i == updateAt:
+			shared = append(shared, &update)
+		case ok:
+			update.changers = append(update.changers, changer)
+		default:
+			shared = append(shared, j)
+		}
+	}
+
+	return &pipeline{jobs: shared}, nil
+}
+
+func (p *pipeline) Name() string {
+	names := make([]string, len(p.jobs))
+	for i, j := range p.jobs {
+		names[i] = j.Name()
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// Outcomes returns the outcomes of all the jobs, each once.
+func (p *pipeline) Outcomes() []string {
+	var names []string
+	for _, j := range p.jobs {
+		for _, name := range j.Outcomes() {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// Run runs the jobs one after another. A failed job stops the jobs after it.
+func (p *pipeline) Run(ctx context.Context, repo RepoContext) (RepoResult, error) {
+	var result RepoResult
+	for _, j := range p.jobs {
+		jobPrinter := repo.Printer.WithPrefix("-")
+		jobPrinter.Info("Running '%s'", j.Name())
+
+		jobResult, err := j.Run(ctx, repo)
+		for _, outcome := range jobResult.Outcomes {
+			result.Add(outcome.Name, outcome.Count, outcome.Items...)
+		}
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", j.Name(), err)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/kaatinga/robot/internal/pretty"
+)
+
+// fakeJob records the repositories it runs in.
+type fakeJob struct {
+	name string
+	err  error
+	ran  []string
+}
+
+func (j *fakeJob) Name() string {
+	return j.name
+}
+
+func (j *fakeJob) Outcomes() []string {
+	return []string{"ran", j.name + " done"}
+}
+
+func (j *fakeJob) Run(_ context.Context, repo RepoContext) (result RepoResult, err error) {
+	j.ran = append(j.ran, repo.Name)
+	result.Add("ran", 1, j.name)
+	if j.err == nil {
+		result.Add(j.name+" done", 1)
+	}
+
+	return result, j.err
+}
+
+// fakeChanger is a job changing files.
+type fakeChanger struct {
+	fakeJob
+}
+
+func (j *fakeChanger) fileChanges(context.Context, RepoContext, baseState) ([]fileChange, error) {
+	return nil, nil
+}
+
+func TestPipeline_Run(t *testing.T) {
+	first, failing, last := &fakeJob{name: "first"}, &fakeJob{name: "failing", err: errors.New("failed")}, &fakeJob{name: "last"}
+	p, err := NewPipeline([]Job{first, failing, last}, false)
+	if err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+
+	if got, want := p.Outcomes(), []string{"ran", "first done", "failing done", "last done"}; !slices.Equal(got, want) {
+		t.Errorf("Outcomes() = %v, want %v", got, want)
+	}
+
+	result, err := p.Run(context.Background(), RepoContext{Name: "robot", Printer: pretty.NewScopePrinterTo(io.Discard, "")})
+	if err == nil || err.Error() != "failing: failed" {
+		t.Errorf("Run() error = %v", err)
+	}
+	if len(first.ran) != 1 || len(failing.ran) != 1 || len(last.ran) != 0 {
+		t.Errorf("jobs ran in %v, %v and %v", first.ran, failing.ran, last.ran)
+	}
+	want := []Outcome{{Name: "ran", Count: 2, Items: []string{"first", "failing"}}, {Name: "first done", Count: 1}}
+	if len(result.Outcomes) != len(want) {
+		t.Fatalf("Run() = %v, want %v", result.Outcomes, want)
+	}
+	for i := range want {
+		if got := result.Outcomes[i]; got.Name != want[i].Name || got.Count != want[i].Count || !slices.Equal(got.Items, want[i].Items) {
+			t.Errorf("Run()[%d] = %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestNewPipeline_sharePullRequest(t *testing.T) {
+	update := &updateWorkflowFilesJob{}
+	changer, other := &fakeChanger{fakeJob{name: "changer"}}, &fakeJob{name: "other"}
+
+	p, err := NewPipeline([]Job{changer, update, other}, true)
+	if err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+
+	jobs := p.(*pipeline).jobs
+	if len(jobs) != 2 || jobs[1] != other {
+		t.Fatalf("jobs = %v, want the update job and other", jobs)
+	}
+	shared, ok := jobs[0].(*updateWorkflowFilesJob)
+	if !ok || len(shared.changers) != 1 || shared.changers[0] != changer {
+		t.Errorf("update job = %v, want it to take over the changes of changer", jobs[0])
+	}
+	if len(update.changers) != 0 {
+		t.Error("NewPipeline() changed the given update job")
+	}
+
+	if _, err = NewPipeline([]Job{changer, other}, true); err == nil {
+		t.Error("NewPipeline() without the update job succeeded")
+	}
+
+	badges := &badgesJob{update: &updateWorkflowFilesJob{}}
+	if p, err = NewPipeline([]Job{update, badges}, true); err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+	if jobs := p.(*pipeline).jobs; len(jobs) != 1 || jobs[0].(*updateWorkflowFilesJob).changers[0] != badges {
+		t.Errorf("jobs = %v, want the update job taking over the changes of badges", jobs)
+	}
+}
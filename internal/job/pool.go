@@ -0,0 +1,158 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/google/go-github/v60/github"
+	"github.com/kaatinga/robot/internal/pretty"
+)
+
+// repoTask is a repository processed by one of the workers of a repoPool.
+type repoTask struct {
+	owner string
+	repo  *github.Repository
+	// output buffers everything printed while the repository is processed,
+	// so that the output of concurrently processed repositories never interleaves.
+	output bytes.Buffer
+	// skipReason is set if the repository was skipped.
+	skipReason string
+	err        error
+	done       chan struct{}
+}
+
+// report returns the outcome of the task.
+func (t *repoTask) report() repoReport {
+	report := repoReport{name: t.owner}
+	if t.repo != nil {
+		report.name = t.repo.GetFullName()
+	}
+
+	switch {
+	case t.err != nil:
+		report.status, report.reason = repoFailed, t.err.Error()
+	case t.skipReason != "":
+		report.status, report.reason = repoSkipped, t.skipReason
+	default:
+		report.status = repoSucceeded
+	}
+
+	return report
+}
+
+// repoPool processes repositories with a bounded number of workers and prints
+// the output of every repository at once, in the order the repositories were added.
+type repoPool struct {
+	tasks   chan *repoTask
+	ordered chan *repoTask
+	printed chan struct{}
+	cancel  context.CancelFunc
+	// reports holds the outcomes of the processed repositories in the order they were added
+	reports []repoReport
+
+	errOnce sync.Once
+	err     error
+}
+
+// newRepoPool starts the workers. If stopOnError is set, the returned context is cancelled
+// as soon as processing of a repository fails.
+func newRepoPool(ctx context.Context, workers int, stopOnError bool, out io.Writer, process func(context.Context, *repoTask) error) (*repoPool, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	workers = max(workers, 1)
+	p := &repoPool{
+		tasks:   make(chan *repoTask),
+		ordered: make(chan *repoTask, workers),
+		printed: make(chan struct{}),
+		cancel:  cancel,
+	}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range p.tasks {
+				task.err = process(ctx, task)
+				switch {
+				case task.err == nil:
+				case stopOnError:
+					p.fail(task.err)
+				default:
+					printer := pretty.NewScopePrinterTo(&task.output, "-")
+					printer.Error("%v", task.err)
+				}
+				close(task.done)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(p.printed)
+		for task := range p.ordered {
+			<-task.done
+			_, _ = out.Write(task.output.Bytes())
+			if task.repo != nil || task.err != nil {
+				p.reports = append(p.reports, task.report())
+			}
+		}
+	}()
+
+	return p, ctx
+}
+
+// fail records the first error and stops processing of the remaining repositories.
+func (p *repoPool) fail(err error) {
+	p.errOnce.Do(func() {
+		p.err = err
+		p.cancel()
+	})
+}
+
+// add queues the repository. It returns false if the context is done and the repository was not queued.
+func (p *repoPool) add(ctx context.Context, owner string, repo *github.Repository) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	task := &repoTask{owner: owner, repo: repo, done: make(chan struct{})}
+	select {
+	case p.ordered <- task:
+	case <-ctx.Done():
+		return false
+	}
+
+	select {
+	case p.tasks <- task:
+		return true
+	case <-ctx.Done():
+		close(task.done)
+		return false
+	}
+}
+
+// addFailure reports a failure that is not related to a single repository, e.g. a failure to list repositories.
+func (p *repoPool) addFailure(name string, err error) {
+	task := &repoTask{owner: name, err: err, done: make(chan struct{})}
+	printer := pretty.NewScopePrinterTo(&task.output, "")
+	printer.Error("%s: %v", name, err)
+	close(task.done)
+	p.ordered <- task
+}
+
+// print prints a message in order with the output of the queued repositories.
+func (p *repoPool) print(print func(printer pretty.ScopePrinter)) {
+	task := &repoTask{done: make(chan struct{})}
+	print(pretty.NewScopePrinterTo(&task.output, ""))
+	close(task.done)
+	p.ordered <- task
+}
+
+// wait waits for all the queued repositories to be processed and printed.
+// It returns the outcomes of the repositories and the error processing was stopped with.
+func (p *repoPool) wait() ([]repoReport, error) {
+	close(p.tasks)
+	close(p.ordered)
+	<-p.printed
+	p.cancel()
+
+	return p.reports, p.err
+}
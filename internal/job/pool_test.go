@@ -0,0 +1,96 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v60/github"
+)
+
+func Test_repoPool(t *testing.T) {
+	var out bytes.Buffer
+	pool, ctx := newRepoPool(context.Background(), 3, true, &out, func(_ context.Context, task *repoTask) error {
+		// the first repositories finish last
+		time.Sleep(time.Duration(5-len(task.repo.GetName())) * 10 * time.Millisecond)
+		fmt.Fprintf(&task.output, "%s\n", task.repo.GetName())
+		return nil
+	})
+
+	for _, name := range []string{"a", "bb", "ccc", "dddd"} {
+		if !pool.add(ctx, "owner", &github.Repository{Name: github.String(name)}) {
+			t.Fatalf("repository '%s' not added", name)
+		}
+	}
+
+	if _, err := pool.wait(); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+
+	if want := "a\nbb\nccc\ndddd\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func Test_repoPool_error(t *testing.T) {
+	errFailed := errors.New("failed")
+	pool, ctx := newRepoPool(context.Background(), 2, true, &bytes.Buffer{}, func(ctx context.Context, task *repoTask) error {
+		if task.repo.GetName() == "broken" {
+			return errFailed
+		}
+		return ctx.Err()
+	})
+
+	pool.add(ctx, "owner", &github.Repository{Name: github.String("broken")})
+	<-ctx.Done()
+	if pool.add(ctx, "owner", &github.Repository{Name: github.String("next")}) {
+		t.Error("repository added after the failure")
+	}
+
+	if _, err := pool.wait(); !errors.Is(err, errFailed) {
+		t.Errorf("wait() error = %v, want %v", err, errFailed)
+	}
+}
+
+func Test_repoPool_continueOnError(t *testing.T) {
+	errFailed := errors.New("failed")
+	pool, ctx := newRepoPool(context.Background(), 2, false, &bytes.Buffer{}, func(_ context.Context, task *repoTask) error {
+		switch task.repo.GetName() {
+		case "broken":
+			return errFailed
+		case "fork":
+			task.skipReason = "Fork"
+		}
+		return nil
+	})
+
+	for _, name := range []string{"broken", "fork", "fine"} {
+		if !pool.add(ctx, "owner", &github.Repository{Name: github.String(name), FullName: github.String("owner/" + name)}) {
+			t.Fatalf("repository '%s' not added", name)
+		}
+	}
+	pool.addFailure("other", errFailed)
+
+	reports, err := pool.wait()
+	if err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+
+	want := []repoReport{
+		{"owner/broken", repoFailed, "failed"},
+		{"owner/fork", repoSkipped, "Fork"},
+		{"owner/fine", repoSucceeded, ""},
+		{"other", repoFailed, "failed"},
+	}
+	if len(reports) != len(want) {
+		t.Fatalf("reports = %v, want %v", reports, want)
+	}
+	for i := range want {
+		if reports[i] != want[i] {
+			t.Errorf("reports[%d] = %v, want %v", i, reports[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/v60/github"
+
+	"github.com/kaatinga/robot/internal/diff"
+)
+
+// Defaults of the pull requests the robot opens.
+const (
+	DefaultPRTitle = "Update Workflow YAML files"
+	// DefaultPRBody lists the changed files with the numbers of the added and removed lines.
+	DefaultPRBody = `This PR updates the files managed by the robot.
+
+| File | Change | Lines |
+|------|--------|-------|
+[[ range .Changes ]]| ` + "`[[ .Path ]]`" + ` | [[ .Action ]] | +[[ .Added ]] -[[ .Removed ]] |
+[[ end ]]
+[[ len .Changes ]] files changed: [[ .Created ]] created, [[ .Updated ]] updated, [[ .Deleted ]] deleted, [[ .Added ]] lines added, [[ .Removed ]] lines removed.
+`
+	DefaultMergeMethod = "merge"
+)
+
+// names of the pull request templates
+const (
+	prTitleTemplate = "title"
+	prBodyTemplate  = "body"
+)
+
+// PullRequestOptions describes the pull requests the robot opens.
+type PullRequestOptions struct {
+	// Title and Body are templates rendered with pullRequestData. They default to DefaultPRTitle and DefaultPRBody.
+	Title string
+	Body  string
+	// Labels are added to the pull requests.
+	Labels []string
+	// Assignees are assigned to the pull requests.
+	Assignees []string
+	// Reviewers and TeamReviewers are requested to review the pull requests.
+	Reviewers     []string
+	TeamReviewers []string
+	// Milestone is the title of the milestone the pull requests are added to. Every repository must have an open
+	// milestone with this title.
+	Milestone string
+	// Draft opens the pull requests as drafts.
+	Draft bool
+}
+
+// RepoOverride holds the options overridden for a single repository.
+type RepoOverride struct {
+	// BaseBranch is the branch the pull request is opened against instead of the default branch.
+	BaseBranch string
+	// TemplateSet is the name of the template set applied instead of the first matching one.
+	TemplateSet string
+	// Values are added to the custom values of the templates, replacing the ones with the same names.
+	Values map[string]string
+	// Keep is added to the keep list of the job.
+	Keep []string
+	// Labels, Assignees and Reviewers are added to the ones given in PullRequestOptions.
+	Labels    []string
+	Assignees []string
+	Reviewers []string
+}
+
+// pullRequestData is the data the title and the body of the pull requests are rendered with.
+// The data of the templates is available as well.
+type pullRequestData struct {
+	templateData
+	// Changes lists the changed files in the order of their paths.
+	Changes []changeSummary
+	// Created, Updated and Deleted are the numbers of the created, updated and deleted files.
+	Created, Updated, Deleted int
+	// Added and Removed are the numbers of the lines added and removed in all the files.
+	Added, Removed int
+}
+
+// changeSummary describes the change of a file in the pull request.
+type changeSummary struct {
+	Path string
+	// Action is Created, Updated or Deleted.
+	Action string
+	// Added and Removed are the numbers of the lines added and removed in the file.
+	Added, Removed int
+}
+
+// newPullRequestData summarizes the changes for the title and the body of the pull request.
+func newPullRequestData(data templateData, changes []fileChange) pullRequestData {
+	prData := pullRequestData{templateData: data}
+	for _, change := range changes {
+		summary := changeSummary{Path: change.path, Action: change.result().String()}
+		summary.Added, summary.Removed = diff.Stat(string(change.oldContent), string(change.content))
+		prData.Added += summary.Added
+		prData.Removed += summary.Removed
+
+		switch change.action {
+		case createAction:
+			prData.Created++
+		case updateAction:
+			prData.Updated++
+		case deleteAction:
+			prData.Deleted++
+		}
+
+		prData.Changes = append(prData.Changes, summary)
+	}
+	sort.Slice(prData.Changes, func(i, j int) bool {
+		return prData.Changes[i].Path < prData.Changes[j].Path
+	})
+
+	return prData
+}
+
+// renderPullRequest renders the title and the body of the pull request.
+func renderPullRequest(templates map[string]*template.Template, data pullRequestData) (title, body string, err error) {
+	var rendered [2]strings.Builder
+	for i, name := range []string{prTitleTemplate, prBodyTemplate} {
+		if err = templates[name].Execute(&rendered[i], data); err != nil {
+			return "", "", fmt.Errorf("error rendering pull request %s: %w", name, err)
+		}
+	}
+
+	return strings.TrimSpace(rendered[0].String()), rendered[1].String(), nil
+}
+
+// pullRequestMetadata is added to a pull request once it is created.
+type pullRequestMetadata struct {
+	labels        []string
+	assignees     []string
+	reviewers     []string
+	teamReviewers []string
+	milestone     string
+}
+
+// listRobotPullRequests returns the open pull requests from the branches of the repository the job whose branches start
+// with the prefix created, to the base branch, the most recent first.
+func listRobotPullRequests(ctx context.Context, owner, repo, base, prefix string) ([]*github.PullRequest, error) {
+	var robotPRs []*github.PullRequest
+	options := &github.PullRequestListOptions{State: "open", Base: base, ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		prs, resp, err := client.PullRequests.List(ctx, owner, repo, options)
+		if err != nil {
+			return nil, fmt.Errorf("error listing pull requests: %w", err)
+		}
+
+		for _, pr := range prs {
+			// the branches of forks may be named alike
+			if pr.GetHead().GetRepo().GetID() == pr.GetBase().GetRepo().GetID() && ownsBranch(prefix, pr.GetHead().GetRef()) {
+				robotPRs = append(robotPRs, pr)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		options.Page = resp.NextPage
+	}
+
+	sort.Slice(robotPRs, func(i, j int) bool {
+		return robotPRs[i].GetNumber() > robotPRs[j].GetNumber()
+	})
+
+	return robotPRs, nil
+}
+
+// closePullRequest comments on the pull request, closes it and deletes its branch.
+func closePullRequest(ctx context.Context, owner, repo string, pr *github.PullRequest, comment string) error {
+	_, _, err := client.Issues.CreateComment(ctx, owner, repo, pr.GetNumber(), &github.IssueComment{Body: github.String(comment)})
+	if err != nil {
+		return fmt.Errorf("error commenting on pull request #%d: %w", pr.GetNumber(), err)
+	}
+
+	_, _, err = client.PullRequests.Edit(ctx, owner, repo, pr.GetNumber(), &github.PullRequest{State: github.String("closed")})
+	if err != nil {
+		return fmt.Errorf("error closing pull request #%d: %w", pr.GetNumber(), err)
+	}
+
+	err = deleteBranch(ctx, owner, repo, pr.GetHead().GetRef())
+	if err != nil {
+		return fmt.Errorf("error deleting branch '%s': %w", pr.GetHead().GetRef(), err)
+	}
+
+	return nil
+}
+
+// validateMergeMethod checks that the method is supported by GitHub.
+func validateMergeMethod(method string) error {
+	switch method {
+	case "merge", "squash", "rebase":
+		return nil
+	default:
+		return fmt.Errorf("invalid merge method '%s': must be 'merge', 'squash' or 'rebase'", method)
+	}
+}
+
+// repoOverride returns the override given for the repository by full name or, failing that, by name.
+func repoOverride(overrides map[string]RepoOverride, repo RepoContext) RepoOverride {
+	for _, key := range []string{repo.FullName, repo.Name} {
+		if override, found := overrides[key]; found {
+			return override
+		}
+	}
+
+	return RepoOverride{}
+}
+
+// mergeValues returns the values with the overrides applied.
+func mergeValues(values, overrides map[string]string) map[string]string {
+	if len(overrides) == 0 {
+		return values
+	}
+
+	merged := make(map[string]string, len(values)+len(overrides))
+	for name, value := range values {
+		merged[name] = value
+	}
+	for name, value := range overrides {
+		merged[name] = value
+	}
+
+	return merged
+}
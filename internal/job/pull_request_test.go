@@ -0,0 +1,207 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-github/v60/github"
+	"github.com/kaatinga/robot/internal/pretty"
+)
+
+func Test_renderPullRequest(t *testing.T) {
+	changes := []fileChange{
+		{path: manifestPath, action: createAction, content: []byte("a\nb\n")},
+		{path: ".github/workflows/test.yml", action: updateAction, content: []byte("a\nc\nd\n"), oldContent: []byte("a\nb\n")},
+		{path: ".github/workflows/old.yml", action: deleteAction, oldContent: []byte("a\n")},
+	}
+	data := newPullRequestData(templateData{Repo: "robot"}, changes)
+
+	tests := []struct {
+		name      string
+		title     string
+		body      string
+		wantTitle string
+		wantBody  string
+	}{
+		{
+			name:      "default body",
+			title:     "Update [[ .Repo ]]: [[ len .Changes ]] files",
+			body:      DefaultPRBody,
+			wantTitle: "Update robot: 3 files",
+			wantBody: "This PR updates the files managed by the robot.\n\n" +
+				"| File | Change | Lines |\n" +
+				"|------|--------|-------|\n" +
+				"| `.github/.robot-managed` | Created | +2 -0 |\n" +
+				"| `.github/workflows/old.yml` | Deleted | +0 -1 |\n" +
+				"| `.github/workflows/test.yml` | Updated | +2 -1 |\n" +
+				"\n" +
+				"3 files changed: 1 created, 1 updated, 1 deleted, 4 lines added, 2 lines removed.\n",
+		},
+		{
+			name:      "custom body",
+			title:     "  Sync  \n",
+			body:      "[[ range .Changes ]][[ .Path ]] [[ end ]]",
+			wantTitle: "Sync",
+			wantBody:  ".github/.robot-managed .github/workflows/old.yml .github/workflows/test.yml ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templates, err := parseTemplates(map[string][]byte{prTitleTemplate: []byte(tt.title), prBodyTemplate: []byte(tt.body)})
+			if err != nil {
+				t.Fatalf("parseTemplates() error = %v", err)
+			}
+
+			title, body, err := renderPullRequest(templates, data)
+			if err != nil {
+				t.Fatalf("renderPullRequest() error = %v", err)
+			}
+			if title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", title, tt.wantTitle)
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_listRobotPullRequests(t *testing.T) {
+	pr := func(number int, branch string, headRepo int64) string {
+		return fmt.Sprintf(`{"number": %d, "head": {"ref": %q, "repo": {"id": %d}}, "base": {"ref": "main", "repo": {"id": 1}}}`, number, branch, headRepo)
+	}
+	tests := []struct {
+		name string
+		// prefix starts the branches of the job listing its pull requests, branchPrefix if empty
+		prefix string
+		// pages are the pages of the open pull requests
+		pages []string
+		want  []int
+	}{
+		{name: "none", pages: []string{`[]`}},
+		{
+			name: "robot branches only",
+			pages: []string{
+				"[" + pr(3, "robot-works-2024-01-01T000000Z", 1) + "," + pr(4, "feature", 1) + "]",
+			},
+			want: []int{3},
+		},
+		{
+			name: "fork branches named alike",
+			pages: []string{
+				"[" + pr(5, "robot-works-2024-01-02T000000Z", 2) + "," + pr(2, "robot-works-2024-01-01T000000Z", 1) + "]",
+			},
+			want: []int{2},
+		},
+		{
+			name: "pull requests of other jobs",
+			pages: []string{
+				"[" + pr(7, "robot-works-badges-2024-01-02T000000Z", 1) + "," + pr(2, "robot-works-2024-01-01T000000Z", 1) + "," +
+					pr(8, "robot-works-manual", 1) + "]",
+			},
+			want: []int{2},
+		},
+		{
+			name:   "pull requests of the badges job",
+			prefix: badgesBranchPrefix,
+			pages: []string{
+				"[" + pr(7, "robot-works-badges-2024-01-02T000000Z", 1) + "," + pr(2, "robot-works-2024-01-01T000000Z", 1) + "]",
+			},
+			want: []int{7},
+		},
+		{
+			name: "newest first over the pages",
+			pages: []string{
+				"[" + pr(2, "robot-works-2024-01-01T000000Z", 1) + "," + pr(9, "robot-works-2024-01-03T000000Z", 1) + "]",
+				"[" + pr(5, "robot-works-2024-01-02T000000Z", 1) + "]",
+			},
+			want: []int{9, 5, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/repos/kaatinga/robot/pulls" || r.URL.Query().Get("state") != "open" || r.URL.Query().Get("base") != "main" {
+					t.Errorf("unexpected request %s", r.URL)
+				}
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				page = max(page, 1)
+				if page < len(tt.pages) {
+					w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=%d>; rel="next"`, r.Host, r.URL.Path, page+1))
+				}
+				fmt.Fprint(w, tt.pages[page-1])
+			}))
+
+			prefix := tt.prefix
+			if prefix == "" {
+				prefix = branchPrefix
+			}
+			prs, err := listRobotPullRequests(context.Background(), "kaatinga", "robot", "main", prefix)
+			if err != nil {
+				t.Fatalf("listRobotPullRequests() error = %v", err)
+			}
+			var got []int
+			for _, pr := range prs {
+				got = append(got, pr.GetNumber())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("listRobotPullRequests() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_closePullRequest(t *testing.T) {
+	var requests []string
+	useTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		fmt.Fprint(w, `{}`)
+	}))
+
+	pr := &github.PullRequest{Number: github.Int(3), Head: &github.PullRequestBranch{Ref: github.String("robot-works-2024-01-01T000000Z")}}
+	if err := closePullRequest(context.Background(), "kaatinga", "robot", pr, "Superseded"); err != nil {
+		t.Fatalf("closePullRequest() error = %v", err)
+	}
+
+	want := []string{
+		"POST /repos/kaatinga/robot/issues/3/comments",
+		"PATCH /repos/kaatinga/robot/pulls/3",
+		"DELETE /repos/kaatinga/robot/git/refs/heads/robot-works-2024-01-01T000000Z",
+	}
+	if !slices.Equal(requests, want) {
+		t.Errorf("closePullRequest() requests = %v, want %v", requests, want)
+	}
+}
+
+func Test_workflowUpdate_openOrUpdatePR_failed(t *testing.T) {
+	requests := serveResponses(t, []apiResponse{
+		{http.StatusUnprocessableEntity, `{"message": "Validation Failed"}`},
+		{http.StatusNoContent, ""},
+	})
+
+	u := &workflowUpdate{
+		updateWorkflowFilesJob: &updateWorkflowFilesJob{},
+		owner:                  "kaatinga",
+		repo:                   "robot",
+		baseBranch:             "main",
+		branch:                 "robot-works-2024-01-01T000000Z",
+		printer:                pretty.NewScopePrinterTo(io.Discard, ""),
+	}
+	if _, err := u.openOrUpdatePR(context.Background()); err == nil {
+		t.Fatal("openOrUpdatePR() error = nil, want an error")
+	}
+
+	// the new branch is not left behind without a pull request
+	want := []string{
+		"POST /repos/kaatinga/robot/pulls",
+		"DELETE /repos/kaatinga/robot/git/refs/heads/robot-works-2024-01-01T000000Z",
+	}
+	if !slices.Equal(*requests, want) {
+		t.Errorf("openOrUpdatePR() requests = %v, want %v", *requests, want)
+	}
+}
@@ -3,97 +3,251 @@ package job
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/google/go-github/v60/github"
 	"github.com/kaatinga/robot/internal/color"
 	"github.com/kaatinga/robot/internal/pretty"
 )
 
-func FetchAllGoRepos(ctx context.Context, j Job, repoJob func(context.Context, *github.Repository) error) error {
-	scopePrinter := pretty.NewScopePrinter("")
-	scopePrinter.Info("Fetching all Go repositories for user '%s'", j.User())
+// ScanOptions narrows down the repositories processed by FetchAllGoRepos.
+type ScanOptions struct {
+	// Owners holds the users and organizations whose repositories are processed.
+	// The authenticated user is used if empty.
+	Owners []string
+	// Filter selects the repositories to process.
+	Filter Filter
+	// Workers is the number of repositories processed concurrently. Repositories are processed one by one if zero.
+	Workers int
+	// ContinueOnError keeps processing the remaining repositories when a repository fails
+	// and prints the status of every repository at the end.
+	ContinueOnError bool
+}
 
-	// List all repositories for the authenticated user
-	opt := &github.RepositoryListByAuthenticatedUserOptions{
-		Sort:        "Updated",
-		ListOptions: github.ListOptions{PerPage: 30},
+// FetchAllGoRepos runs the job in every Go repository of the owners selected by the options
+// and prints the summary of the outcomes of the job.
+func FetchAllGoRepos(ctx context.Context, j Job, options ScanOptions) error {
+	if err := options.Filter.Validate(); err != nil {
+		return err
 	}
 
-	for {
-		repos, listRepos, err := client.Repositories.ListByAuthenticatedUser(ctx, opt)
+	owners := options.Owners
+	if len(owners) == 0 {
+		authenticatedUser, err := AuthenticatedUser(ctx)
 		if err != nil {
-			return fmt.Errorf("Error listing repositories: %v\n", err)
-		}
-		if listRepos.Rate.Remaining < 300 {
-			scopePrinter.Info("Rate limit reached. Remaining Quota: %d", listRepos.Rate.Remaining)
-			break
-		} else {
-			scopePrinter.Info("Remaining Quota: %d", listRepos.Rate.Remaining)
+			return err
 		}
+		owners = []string{authenticatedUser}
+	}
 
-		for _, repo := range repos {
-			scopePrinter.Info("Processing repository '%s'", repo.GetName())
-			j.Next() // Reset the branchCreated and other flags
-			loopPrinter := pretty.NewScopePrinter("-")
-			if skipRepo(ctx, repo, loopPrinter, j.User()) {
-				continue
-			}
+	summary := newOutcomes(j.Outcomes()...)
+	pool, poolCtx := newRepoPool(ctx, options.Workers, !options.ContinueOnError, os.Stdout, func(ctx context.Context, task *repoTask) error {
+		result, err := processRepo(ctx, task, options.Filter, j)
+		summary.add(result)
+		return err
+	})
 
-			loopPrinter.Info("Golang package/project detected")
+	if err := listAllRepos(poolCtx, owners, pool, options.ContinueOnError); err != nil {
+		pool.fail(err)
+	}
 
-			if err := repoJob(ctx, repo); err != nil {
-				return err
-			}
+	reports, err := pool.wait()
+	if err != nil {
+		return err
+	}
+
+	println()
+	fmt.Println(color.Faint + "------- " + j.Name() + " finished -------" + color.Reset)
+	printSummary(pretty.NewScopePrinter(""), summary.Outcomes())
+
+	if !options.ContinueOnError {
+		return nil
+	}
+
+	println()
+	printReports(os.Stdout, reports)
+
+	if failed := countFailed(reports); failed != 0 {
+		return fmt.Errorf("%d of %d repositories failed", failed, len(reports))
+	}
+
+	return nil
+}
+
+// printSummary prints the outcomes counted at least once, each followed by its items.
+func printSummary(printer pretty.ScopePrinter, outcomes []Outcome) {
+	itemPrinter := printer.WithPrefix("--")
+	var printed bool
+	for _, outcome := range outcomes {
+		if outcome.Count == 0 {
+			continue
 		}
 
-		if listRepos.NextPage == 0 {
-			break
+		printer.OK("%d %s", outcome.Count, outcome.Name)
+		for _, item := range outcome.Items {
+			itemPrinter.Info("%s", item)
 		}
-		opt.Page = listRepos.NextPage
+		printed = true
 	}
 
-	println()
-	fmt.Println(color.Faint + "------- updateWorkflowFilesJob Finished -------" + color.Reset)
-	if j.Counter() == 0 {
-		scopePrinter.Info("No Pull Requests created in Go repositories by this job")
-		return nil
+	if !printed {
+		printer.Info("Nothing to do in the Go repositories")
+	}
+}
+
+// listAllRepos lists the repositories of the owners and adds them to the pool.
+// If continueOnError is set, a failure to list the repositories of an owner is reported
+// and the repositories of the remaining owners are still listed.
+func listAllRepos(ctx context.Context, owners []string, pool *repoPool, continueOnError bool) error {
+	for _, owner := range owners {
+		err := listOwnerRepos(ctx, owner, pool)
+		switch {
+		case err == nil:
+		case ctx.Err() != nil:
+			return nil
+		case continueOnError:
+			pool.addFailure(owner, err)
+		default:
+			return err
+		}
 	}
 
-	scopePrinter.OK("%d Pull Requests created in Go repositories", j.Counter())
-	scopePrinter.AddPrefix("--")
-	for _, pr := range j.PRURLs() {
-		scopePrinter.Info(pr)
+	return nil
+}
+
+// listOwnerRepos lists the repositories of the owner and adds them to the pool.
+func listOwnerRepos(ctx context.Context, owner string, pool *repoPool) error {
+	listPage, err := ownerRepoLister(ctx, owner)
+	if err != nil {
+		return err
+	}
+
+	pool.print(func(printer pretty.ScopePrinter) {
+		printer.Info("Fetching all Go repositories of '%s'", owner)
+	})
+
+	for page := 1; page != 0; {
+		repos, listRepos, err := listPage(page)
+		if err != nil {
+			return fmt.Errorf("Error listing repositories: %v\n", err)
+		}
+		pool.print(func(printer pretty.ScopePrinter) {
+			printer.Info("Remaining Quota: %d", listRepos.Rate.Remaining)
+		})
+
+		for _, repo := range repos {
+			if !pool.add(ctx, owner, repo) {
+				return ctx.Err()
+			}
+		}
+
+		page = listRepos.NextPage
 	}
 
 	return nil
 }
 
-func skipRepo(ctx context.Context, repo *github.Repository, loopPrinter pretty.ScopePrinter, user string) bool {
+// processRepo runs the job for the repository unless the repository is skipped.
+func processRepo(ctx context.Context, task *repoTask, filter Filter, j Job) (RepoResult, error) {
+	scopePrinter := pretty.NewScopePrinterTo(&task.output, "")
+	scopePrinter.Info("Processing repository '%s'", task.repo.GetFullName())
+
+	loopPrinter := scopePrinter.WithPrefix("-")
+	if !strings.EqualFold(task.repo.GetOwner().GetLogin(), task.owner) {
+		task.skipReason = fmt.Sprintf("Owned by '%s'", task.repo.GetOwner().GetLogin())
+		loopPrinter.Skipped(task.skipReason)
+		return RepoResult{}, nil
+	}
+
+	mod, reason, err := skipRepo(ctx, task.repo, filter)
+	if err != nil {
+		return RepoResult{}, err
+	}
+	if reason != "" {
+		task.skipReason = reason
+		loopPrinter.Skipped(reason)
+		return RepoResult{}, nil
+	}
+
+	loopPrinter.Info("Golang package/project detected: %s", mod.Module)
+
+	return j.Run(ctx, newRepoContext(task.repo, mod, scopePrinter))
+}
+
+// skipRepo returns the reason the repository is skipped, or an empty string if the repository has to be processed.
+func skipRepo(ctx context.Context, repo *github.Repository, filter Filter) (GoMod, string, error) {
 	if repo.GetFork() {
-		loopPrinter.Skipped("Fork")
-		return true
+		return GoMod{}, "Fork", nil
 	}
 
 	if repo.GetArchived() {
-		loopPrinter.Skipped("Archived")
-		return true
+		return GoMod{}, "Archived", nil
 	}
 
-	if repo.GetOwner().GetType() != "User" {
-		loopPrinter.Skipped("Not a user repository: " + repo.GetOwner().GetType())
-		return true
+	if reason := filter.skipReason(repo); reason != "" {
+		return GoMod{}, reason, nil
 	}
 
 	// Check for go.mod file in the repository's root
-	_, _, resp, err := client.Repositories.GetContents(ctx, user, repo.GetName(), "go.mod", &github.RepositoryContentGetOptions{})
+	file, _, resp, err := client.Repositories.GetContents(ctx, repo.GetOwner().GetLogin(), repo.GetName(), "go.mod", &github.RepositoryContentGetOptions{})
 	if err != nil {
 		if resp != nil && resp.StatusCode == 404 {
-			loopPrinter.Skipped("go.mod is not in the root directory")
-		} else {
-			loopPrinter.Error("Error getting contents: %v", err)
+			return GoMod{}, "go.mod is not in the root directory", nil
 		}
-		return true
+		return GoMod{}, "", fmt.Errorf("Error getting contents: %v", err)
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return GoMod{}, fmt.Sprintf("go.mod cannot be decoded: %v", err), nil
+	}
+
+	mod, err := parseGoMod([]byte(content))
+	if err != nil {
+		return GoMod{}, fmt.Sprintf("go.mod cannot be parsed: %v", err), nil
+	}
+
+	return mod, "", nil
+}
+
+// ownerRepoLister returns a function listing a page of the repositories of a user or an organization.
+// The private repositories of a user can only be listed if the user is authenticated.
+func ownerRepoLister(ctx context.Context, owner string) (func(page int) ([]*github.Repository, *github.Response, error), error) {
+	user, _, err := client.Users.Get(ctx, owner)
+	if err != nil {
+		return nil, fmt.Errorf("error getting owner '%s': %w", owner, err)
+	}
+
+	const perPage = 30
+	if user.GetType() == "Organization" {
+		return func(page int) ([]*github.Repository, *github.Response, error) {
+			return client.Repositories.ListByOrg(ctx, owner, &github.RepositoryListByOrgOptions{
+				Sort:        "updated",
+				ListOptions: github.ListOptions{Page: page, PerPage: perPage},
+			})
+		}, nil
+	}
+
+	authenticatedUser, err := AuthenticatedUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(authenticatedUser, owner) {
+		return func(page int) ([]*github.Repository, *github.Response, error) {
+			return client.Repositories.ListByAuthenticatedUser(ctx, &github.RepositoryListByAuthenticatedUserOptions{
+				Sort:        "updated",
+				Affiliation: "owner",
+				ListOptions: github.ListOptions{Page: page, PerPage: perPage},
+			})
+		}, nil
 	}
 
-	return false
+	return func(page int) ([]*github.Repository, *github.Response, error) {
+		return client.Repositories.ListByUser(ctx, owner, &github.RepositoryListByUserOptions{
+			Sort:        "updated",
+			ListOptions: github.ListOptions{Page: page, PerPage: perPage},
+		})
+	}, nil
 }
@@ -0,0 +1,77 @@
+package job
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/kaatinga/robot/internal/color"
+)
+
+type repoStatus byte
+
+const (
+	repoSucceeded repoStatus = iota
+	repoSkipped
+	repoFailed
+)
+
+func (s repoStatus) String() string {
+	switch s {
+	case repoSucceeded:
+		return "Succeeded"
+	case repoSkipped:
+		return "Skipped"
+	case repoFailed:
+		return "Failed"
+	default:
+		return "Unknown"
+	}
+}
+
+func (s repoStatus) color() string {
+	switch s {
+	case repoSucceeded:
+		return color.Green
+	case repoSkipped:
+		return color.Yellow
+	default:
+		return color.Red
+	}
+}
+
+// repoReport is the outcome of processing of a single repository.
+type repoReport struct {
+	name   string
+	status repoStatus
+	reason string
+}
+
+// printReports prints the outcomes of the repositories as a table followed by the number of repositories per status.
+func printReports(out io.Writer, reports []repoReport) {
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "REPOSITORY\tSTATUS\tREASON")
+
+	var counts [repoFailed + 1]int
+	for _, report := range reports {
+		counts[report.status]++
+		fmt.Fprintf(table, "%s\t%s\t%s\n", report.name, report.status, report.reason)
+	}
+	_ = table.Flush()
+
+	fmt.Fprintf(out, "%s%d succeeded%s, %s%d skipped%s, %s%d failed%s\n",
+		repoSucceeded.color(), counts[repoSucceeded], color.Reset,
+		repoSkipped.color(), counts[repoSkipped], color.Reset,
+		repoFailed.color(), counts[repoFailed], color.Reset,
+	)
+}
+
+func countFailed(reports []repoReport) (failed int) {
+	for _, report := range reports {
+		if report.status == repoFailed {
+			failed++
+		}
+	}
+
+	return
+}
@@ -0,0 +1,106 @@
+package job
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// The templates use [[ and ]] as delimiters, as {{ and }} are taken by the GitHub Actions expressions.
+const (
+	templateLeftDelim  = "[["
+	templateRightDelim = "]]"
+)
+
+// templateData is the data the templates are rendered with for every repository.
+type templateData struct {
+	// Owner is the login of the repository owner.
+	Owner string
+	// Repo is the repository name.
+	Repo string
+	// DefaultBranch is the branch the pull request is opened against.
+	DefaultBranch string
+	// ModulePath is the module path given in go.mod.
+	ModulePath string
+	// GoVersion is the version given in the go directive of go.mod, or "stable" if there is no directive.
+	GoVersion string
+	// GoToolchain is the version given in the toolchain directive of go.mod, or GoVersion if there is no directive.
+	GoToolchain string
+	// GoVersions holds the minor Go versions from the one given in the go directive up to the latest stable one.
+	GoVersions []string
+	// LatestGoVersion is the latest stable Go version.
+	LatestGoVersion string
+	// Values holds the custom values given in the options.
+	Values map[string]string
+}
+
+// templateFuncs are the functions available in the templates.
+var templateFuncs = template.FuncMap{
+	// yamlList formats the items as a YAML flow sequence of quoted strings, e.g. ['1.21', '1.22'].
+	"yamlList": func(items []string) string {
+		quoted := make([]string, len(items))
+		for i, item := range items {
+			quoted[i] = "'" + strings.ReplaceAll(item, "'", "''") + "'"
+		}
+
+		return "[" + strings.Join(quoted, ", ") + "]"
+	},
+}
+
+// newTemplateData returns the data to render the templates for a repository with.
+func newTemplateData(owner, repo, defaultBranch string, mod GoMod, latestGoVersion string, values map[string]string) templateData {
+	data := templateData{
+		Owner:           owner,
+		Repo:            repo,
+		DefaultBranch:   defaultBranch,
+		ModulePath:      mod.Module,
+		GoVersion:       mod.Go,
+		GoToolchain:     mod.Toolchain,
+		GoVersions:      goVersionMatrix(mod.Go, latestGoVersion),
+		LatestGoVersion: latestGoVersion,
+		Values:          values,
+	}
+	if data.GoVersion == "" {
+		data.GoVersion = "stable"
+	}
+	if data.GoToolchain == "" {
+		data.GoToolchain = data.GoVersion
+	}
+
+	return data
+}
+
+// parseTemplates parses the content of the templates. Returns a map of template name to parsed template.
+func parseTemplates(files map[string][]byte) (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template, len(files))
+	for name, content := range files {
+		tmpl, err := template.New(name).
+			Delims(templateLeftDelim, templateRightDelim).
+			Funcs(templateFuncs).
+			Option("missingkey=error").
+			Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing template: %w", err)
+		}
+
+		templates[name] = tmpl
+	}
+
+	return templates, nil
+}
+
+// renderTemplates renders the templates with the data. Returns a map of template name to rendered content.
+func renderTemplates(templates map[string]*template.Template, data templateData) (map[string][]byte, error) {
+	files := make(map[string][]byte, len(templates))
+	for name, tmpl := range templates {
+		var content bytes.Buffer
+		if err := tmpl.Execute(&content, data); err != nil {
+			return nil, fmt.Errorf("error rendering template: %w", err)
+		}
+
+		files[name] = content.Bytes()
+	}
+
+	return files, nil
+}
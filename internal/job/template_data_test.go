@@ -0,0 +1,61 @@
+package job
+
+import "testing"
+
+func Test_renderTemplates(t *testing.T) {
+	templates, err := parseTemplates(map[string][]byte{
+		"test.yml": []byte("go-version: '[[ .GoVersion ]]'\ntoken: ${{ secrets.TOKEN }}\nrepo: [[ .Owner ]]/[[ .Repo ]]@[[ .DefaultBranch ]]\n"),
+		"vars.yml": []byte("[[ .Values.runner ]]"),
+	})
+	if err != nil {
+		t.Fatalf("parseTemplates() error = %v", err)
+	}
+
+	data := templateData{
+		Owner:         "kaatinga",
+		Repo:          "robot",
+		DefaultBranch: "main",
+		GoVersion:     "1.21",
+		Values:        map[string]string{"runner": "ubuntu-latest"},
+	}
+	files, err := renderTemplates(templates, data)
+	if err != nil {
+		t.Fatalf("renderTemplates() error = %v", err)
+	}
+
+	if want := "go-version: '1.21'\ntoken: ${{ secrets.TOKEN }}\nrepo: kaatinga/robot@main\n"; string(files["test.yml"]) != want {
+		t.Errorf("test.yml = %q, want %q", files["test.yml"], want)
+	}
+	if want := "ubuntu-latest"; string(files["vars.yml"]) != want {
+		t.Errorf("vars.yml = %q, want %q", files["vars.yml"], want)
+	}
+
+	data.Values = nil
+	if _, err = renderTemplates(templates, data); err == nil {
+		t.Error("renderTemplates() with a missing value succeeded")
+	}
+}
+
+func Test_newTemplateData(t *testing.T) {
+	templates, err := parseTemplates(map[string][]byte{
+		"test.yml": []byte("go: [[ yamlList .GoVersions ]]\ntoolchain: [[ .GoToolchain ]]\n"),
+	})
+	if err != nil {
+		t.Fatalf("parseTemplates() error = %v", err)
+	}
+
+	data := newTemplateData("kaatinga", "robot", "main", GoMod{Module: "github.com/kaatinga/robot", Go: "1.21"}, "1.23.2", nil)
+	files, err := renderTemplates(templates, data)
+	if err != nil {
+		t.Fatalf("renderTemplates() error = %v", err)
+	}
+
+	if want := "go: ['1.21', '1.22', '1.23']\ntoolchain: 1.21\n"; string(files["test.yml"]) != want {
+		t.Errorf("test.yml = %q, want %q", files["test.yml"], want)
+	}
+
+	data = newTemplateData("kaatinga", "robot", "main", GoMod{Module: "github.com/kaatinga/robot"}, "", nil)
+	if data.GoVersion != "stable" || data.GoToolchain != "stable" || len(data.GoVersions) != 0 {
+		t.Errorf("newTemplateData() without versions = %+v", data)
+	}
+}
@@ -0,0 +1,211 @@
+package job
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/kaatinga/robot/internal/templates"
+)
+
+// DefaultTemplateSet is the name of the template set made of the embedded templates and the ones in TemplateOptions.Dir.
+// It is applied to the repositories no other set matches.
+const DefaultTemplateSet = "default"
+
+// embeddedOrigin is the origin of the embedded templates.
+const embeddedOrigin = "embedded"
+
+// TemplateOptions selects the templates applied to the repositories.
+type TemplateOptions struct {
+	// Dir is the directory with the templates of the default set. Its templates replace the embedded ones
+	// with the same names and extend the others.
+	Dir string
+	// NoEmbedded leaves the embedded templates out of the default set.
+	NoEmbedded bool
+	// Sets are applied to the repositories they match instead of the default set. The first matching set is applied.
+	Sets []TemplateSet
+}
+
+// TemplateSet is a named set of templates applied to the repositories it matches.
+type TemplateSet struct {
+	Name string
+	// Dir is the directory the templates of the set are loaded from.
+	Dir string
+	// Extends is the name of the set whose templates are inherited.
+	// The templates of the set replace the inherited ones with the same names.
+	Extends string
+	// Repos holds glob patterns of repository names and Topics holds topics. A repository is matched
+	// if its name matches one of the patterns or it has one of the topics.
+	Repos  []string
+	Topics []string
+}
+
+// matches reports whether the rules of the set match the repository.
+func (s TemplateSet) matches(repo RepoContext) bool {
+	return matchAny(s.Repos, repo.Name) || containsAny(s.Topics, repo.topics)
+}
+
+// templateSet is a template set with the inherited templates loaded and parsed.
+type templateSet struct {
+	TemplateSet
+	files map[string]templateFile
+	// templates are the parsed templates of the files rendered with text/template
+	templates map[string]*template.Template
+}
+
+// render returns the content of every file of the set for a repository: the templates rendered with the data
+// and the other files as they are.
+func (s templateSet) render(data templateData) (map[string][]byte, error) {
+	rendered, err := renderTemplates(s.templates, data)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, file := range s.files {
+		if !file.render {
+			rendered[name] = file.content
+		}
+	}
+
+	return rendered, nil
+}
+
+// loadTemplateSets loads the default set and the other sets given in the options, resolving the inheritance.
+// The sets are returned in the order they are given, followed by the default set.
+func loadTemplateSets(options TemplateOptions) ([]templateSet, error) {
+	sets := options.Sets
+	definitions := map[string]TemplateSet{DefaultTemplateSet: {Name: DefaultTemplateSet, Dir: options.Dir}}
+	for _, set := range sets {
+		if _, found := definitions[set.Name]; found || set.Name == "" {
+			return nil, fmt.Errorf("invalid template set name '%s': must be unique and not empty", set.Name)
+		}
+		definitions[set.Name] = set
+	}
+
+	files := make(map[string]map[string]templateFile, len(definitions))
+	var resolve func(name string, chain []string) (map[string]templateFile, error)
+	resolve = func(name string, chain []string) (map[string]templateFile, error) {
+		if resolved, found := files[name]; found {
+			return resolved, nil
+		}
+
+		for _, inheriting := range chain {
+			if inheriting == name {
+				return nil, fmt.Errorf("template set inheritance cycle: %s -> %s", strings.Join(chain, " -> "), name)
+			}
+		}
+
+		set, found := definitions[name]
+		if !found {
+			return nil, fmt.Errorf("template set '%s' extends unknown set '%s'", chain[len(chain)-1], name)
+		}
+
+		resolved := make(map[string]templateFile)
+		if set.Extends != "" {
+			inherited, err := resolve(set.Extends, append(chain, name))
+			if err != nil {
+				return nil, err
+			}
+			for fileName, file := range inherited {
+				resolved[fileName] = file
+			}
+		}
+
+		if name == DefaultTemplateSet && !options.NoEmbedded {
+			embedded, err := loadTemplates(templates.Defaults(), embeddedOrigin)
+			if err != nil {
+				return nil, fmt.Errorf("error loading the embedded templates: %w", err)
+			}
+			for fileName, file := range embedded {
+				resolved[fileName] = file
+			}
+		}
+
+		if set.Dir != "" {
+			if _, err := os.Stat(set.Dir); err != nil {
+				return nil, fmt.Errorf("error loading template set '%s': %w", name, err)
+			}
+
+			own, err := loadTemplates(os.DirFS(set.Dir), set.Dir)
+			if err != nil {
+				return nil, fmt.Errorf("error loading template set '%s': %w", name, err)
+			}
+			for fileName, file := range own {
+				resolved[fileName] = file
+			}
+		}
+
+		files[name] = resolved
+		return resolved, nil
+	}
+
+	loaded := make([]templateSet, 0, len(definitions))
+	for _, set := range append(append([]TemplateSet(nil), sets...), definitions[DefaultTemplateSet]) {
+		setFiles, err := resolve(set.Name, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(setFiles) == 0 {
+			return nil, fmt.Errorf("no templates found in template set '%s'", set.Name)
+		}
+
+		if _, found := setFiles[manifestPath]; found {
+			return nil, fmt.Errorf("template set '%s': %s is written by the robot and cannot be a template", set.Name, manifestPath)
+		}
+
+		contents := make(map[string][]byte, len(setFiles))
+		for fileName, file := range setFiles {
+			if file.render {
+				contents[fileName] = file.content
+			}
+		}
+
+		parsed, err := parseTemplates(contents)
+		if err != nil {
+			return nil, fmt.Errorf("template set '%s': %w", set.Name, err)
+		}
+
+		loaded = append(loaded, templateSet{TemplateSet: set, files: setFiles, templates: parsed})
+	}
+
+	return loaded, nil
+}
+
+// selectTemplateSet returns the set given by name, or the first set matching the repository if name is empty.
+// The default set is the last one, so it is returned if no other set matches.
+func selectTemplateSet(sets []templateSet, name string, repo RepoContext) (templateSet, error) {
+	for _, set := range sets {
+		if name == set.Name || (name == "" && (set.matches(repo) || set.Name == DefaultTemplateSet)) {
+			return set, nil
+		}
+	}
+
+	return templateSet{}, fmt.Errorf("unknown template set '%s'", name)
+}
+
+// TemplateInfo describes a template of a template set.
+type TemplateInfo struct {
+	Set  string
+	Name string
+	// Origin is the path of the template file on disk, or in the embedded templates prefixed with "embedded/".
+	Origin string
+}
+
+// ListTemplates returns the templates of every set in the order the sets are matched, the default set being the last one.
+func ListTemplates(options TemplateOptions) ([]TemplateInfo, error) {
+	sets, err := loadTemplateSets(options)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []TemplateInfo
+	for _, set := range sets {
+		for _, name := range sortedKeys(set.files) {
+			infos = append(infos, TemplateInfo{Set: set.Name, Name: name, Origin: set.files[name].origin})
+		}
+	}
+
+	return infos, nil
+}
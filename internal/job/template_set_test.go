@@ -0,0 +1,158 @@
+package job
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v60/github"
+	"github.com/kaatinga/robot/internal/pretty"
+)
+
+func Test_loadTemplateSets(t *testing.T) {
+	root := t.TempDir()
+	for file, content := range map[string]string{
+		"default/test.yml.tmpl": `[[ "test" ]]`,
+		"default/lint.yml":      "lint",
+		"library/lint.yml":      "strict lint",
+		"service/release.yml":   "release",
+		"standalone/build.yml":  "build",
+		"standalone/Makefile":   "build:\n\tif [[ -f go.mod ]]; then go build ./...; fi\n",
+	} {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sets, err := loadTemplateSets(TemplateOptions{
+		Dir:        filepath.Join(root, "default"),
+		NoEmbedded: true,
+		Sets: []TemplateSet{
+			{Name: "service", Extends: "library", Dir: filepath.Join(root, "service"), Repos: []string{"*-service"}},
+			{Name: "library", Extends: DefaultTemplateSet, Dir: filepath.Join(root, "library"), Topics: []string{"library"}},
+			{Name: "standalone", Dir: filepath.Join(root, "standalone")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("loadTemplateSets() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		override  string
+		repo      *github.Repository
+		wantSet   string
+		wantFiles map[string]string
+	}{
+		{
+			name:      "matched by name, inherited twice",
+			repo:      &github.Repository{Name: github.String("billing-service"), Topics: []string{"library"}},
+			wantSet:   "service",
+			wantFiles: map[string]string{"test.yml": "test", "lint.yml": "strict lint", "release.yml": "release"},
+		},
+		{
+			name:      "matched by topic",
+			repo:      &github.Repository{Name: github.String("settings"), Topics: []string{"Library"}},
+			wantSet:   "library",
+			wantFiles: map[string]string{"test.yml": "test", "lint.yml": "strict lint"},
+		},
+		{
+			name:      "not matched",
+			repo:      &github.Repository{Name: github.String("robot")},
+			wantSet:   DefaultTemplateSet,
+			wantFiles: map[string]string{"test.yml": "test", "lint.yml": "lint"},
+		},
+		{
+			name:      "given by name",
+			override:  "standalone",
+			repo:      &github.Repository{Name: github.String("billing-service")},
+			wantSet:   "standalone",
+			wantFiles: map[string]string{"build.yml": "build", "Makefile": "build:\n\tif [[ -f go.mod ]]; then go build ./...; fi\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := selectTemplateSet(sets, tt.override, newRepoContext(tt.repo, GoMod{}, pretty.NewScopePrinter("")))
+			if err != nil {
+				t.Fatalf("selectTemplateSet() error = %v", err)
+			}
+			if set.Name != tt.wantSet {
+				t.Errorf("selectTemplateSet() = %s, want %s", set.Name, tt.wantSet)
+			}
+
+			files, err := set.render(templateData{})
+			if err != nil {
+				t.Fatalf("render() error = %v", err)
+			}
+			if len(files) != len(tt.wantFiles) {
+				t.Errorf("files = %v, want %v", sortedKeys(files), sortedKeys(tt.wantFiles))
+			}
+			for file, want := range tt.wantFiles {
+				if got := string(files[file]); got != want {
+					t.Errorf("%s = %q, want %q", file, got, want)
+				}
+			}
+		})
+	}
+
+	_, err = loadTemplateSets(TemplateOptions{
+		NoEmbedded: true,
+		Sets: []TemplateSet{
+			{Name: "a", Extends: "b"},
+			{Name: "b", Extends: "a"},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("loadTemplateSets() with a cycle error = %v", err)
+	}
+
+	// a template and a file copied as it is cannot be written to the same path
+	if err = os.WriteFile(filepath.Join(root, "default/test.yml"), []byte("test"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loadTemplateSets(TemplateOptions{Dir: filepath.Join(root, "default"), NoEmbedded: true}); err == nil {
+		t.Error("loadTemplateSets() with test.yml and test.yml.tmpl succeeded")
+	}
+}
+
+func TestListTemplates(t *testing.T) {
+	dir := t.TempDir()
+	for file, content := range map[string]string{
+		".github/workflows/test.yml": "custom test",
+		".github/dependabot.yml":     "dependabot",
+		".golangci.yml":              "linters",
+	} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	infos, err := ListTemplates(TemplateOptions{Dir: dir})
+	if err != nil {
+		t.Fatalf("ListTemplates() error = %v", err)
+	}
+
+	want := []TemplateInfo{
+		{Set: DefaultTemplateSet, Name: ".github/dependabot.yml", Origin: filepath.Join(dir, ".github/dependabot.yml")},
+		{Set: DefaultTemplateSet, Name: ".github/workflows/golangci-lint.yml", Origin: "embedded/.github/workflows/golangci-lint.yml.tmpl"},
+		{Set: DefaultTemplateSet, Name: ".github/workflows/test.yml", Origin: filepath.Join(dir, ".github/workflows/test.yml")},
+		{Set: DefaultTemplateSet, Name: ".golangci.yml", Origin: filepath.Join(dir, ".golangci.yml")},
+	}
+	if len(infos) != len(want) {
+		t.Fatalf("ListTemplates() = %v, want %v", infos, want)
+	}
+	for i := range want {
+		if infos[i] != want[i] {
+			t.Errorf("ListTemplates()[%d] = %v, want %v", i, infos[i], want[i])
+		}
+	}
+}
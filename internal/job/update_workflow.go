@@ -2,380 +2,651 @@ package job
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/google/go-github/v60/github"
+	"github.com/kaatinga/robot/internal/diff"
 	"github.com/kaatinga/robot/internal/pretty"
 )
 
 type updateWorkflowFilesJob struct {
-	user         string
 	PRBranchName string
-	baseBranch   string
-	toMerge      bool
+	// prBranchPrefix starts the names of the branches of the job, telling its pull requests from the ones of other jobs
+	prBranchPrefix string
+	toMerge        bool
+	dryRun         bool
+	templateSets   []templateSet
+	mergeMethod    string
+	// autoMerge enables the native auto-merge instead of merging directly
+	autoMerge bool
+	// waitForChecks delays merging until the checks of the pull request pass
+	waitForChecks  bool
+	checksTimeout  time.Duration
+	checksInterval time.Duration
+	pullRequest    PullRequestOptions
+	// prTemplates are the parsed title and body of the pull requests
+	prTemplates map[string]*template.Template
+	overrides   map[string]RepoOverride
+	keep        []string
+	values      map[string]string
+	// latestGoVersion is the latest stable Go version the version matrix in the templates ends with
+	latestGoVersion string
+	// changers are the jobs whose changes are committed along with the changes of the templates
+	changers []fileChanger
+}
 
-	// repo related fields
-	branchCreated bool
-	filesToUpdate map[string][]byte
+// outcomes of the update job
+const (
+	outcomePRsCreated    = "pull requests created"
+	outcomePRsUpdated    = "pull requests updated"
+	outcomePRsUpToDate   = "pull requests already up to date"
+	outcomePRsPlanned    = "pull requests planned"
+	outcomePRsMerged     = "pull requests merged"
+	outcomePRsAutoMerged = "pull requests set to auto-merge"
+	outcomePRsClosed     = "pull requests closed"
+	outcomeFilesCreated  = "files created"
+	outcomeFilesUpdated  = "files updated"
+	outcomeFilesDeleted  = "files deleted"
+)
 
-	prURLs []string
+// workflowUpdate holds the state of the update of a single repository.
+type workflowUpdate struct {
+	*updateWorkflowFilesJob
+	repoContext RepoContext
+	owner       string
+	repo        string
+	baseBranch  string
+	// branch is the branch of the pull request: the branch of the job or the one of the open robot pull request
+	branch  string
+	printer pretty.ScopePrinter
+	// mod is the go.mod file in the root of the repository
+	mod GoMod
+	// set is the template set selected for the repository, nil if the job has no templates
+	set *templateSet
+	// override holds the options overridden for the repository
+	override RepoOverride
+	// data is the data the templates are rendered with
+	data templateData
+	// filesToUpdate holds the templates rendered for the repository
+	filesToUpdate map[string][]byte
+	// changes are the changes committed to the pull request branch
+	changes []fileChange
+	// title and body of the pull request rendered for the changes
+	title, body string
+	// openPR is the open robot pull request updated instead of creating a new one
+	openPR *github.PullRequest
+	// upToDate reports that openPR already holds the changes
+	upToDate bool
+	// headSHA is the commit holding the changes the branch points to, the one checked and merged
+	headSHA string
+	// superseded are the other open robot pull requests, closed once the changes are in a pull request
+	superseded []*github.PullRequest
+	// result holds the outcomes of the update
+	result RepoResult
+}
 
-	counter uint16
+func (j *updateWorkflowFilesJob) Name() string {
+	return "update-workflows"
 }
 
-func (j *updateWorkflowFilesJob) PRURLs() []string {
-	return j.prURLs
+func (j *updateWorkflowFilesJob) Outcomes() []string {
+	return []string{outcomePRsCreated, outcomePRsUpdated, outcomePRsUpToDate, outcomePRsPlanned,
+		outcomePRsMerged, outcomePRsAutoMerged, outcomePRsClosed,
+		outcomeFilesCreated, outcomeFilesUpdated, outcomeFilesDeleted}
 }
 
-func (j *updateWorkflowFilesJob) Next() {
-	j.branchCreated = false
-	j.baseBranch = "main"
+// UpdateWorkflowOptions configures the job created by NewUpdateWorkflowJob.
+type UpdateWorkflowOptions struct {
+	// Templates selects the templates applied to the repositories.
+	Templates TemplateOptions
+	// Merge enables merging of the created pull requests.
+	Merge bool
+	// DryRun prints the planned changes instead of making them.
+	DryRun bool
+	// MergeMethod is merge, squash or rebase; DefaultMergeMethod is used if empty.
+	MergeMethod string
+	// AutoMerge enables the native auto-merge of the pull requests instead of merging them directly,
+	// so that GitHub merges them once the requirements of the base branch are met. Requires Merge.
+	AutoMerge bool
+	// WaitForChecks delays merging until all the commit statuses and check runs of the pull requests pass.
+	// Requires Merge and cannot be combined with AutoMerge.
+	WaitForChecks bool
+	// ChecksTimeout and ChecksInterval default to DefaultChecksTimeout and DefaultChecksInterval.
+	ChecksTimeout  time.Duration
+	ChecksInterval time.Duration
+	// PullRequest describes the created pull requests.
+	PullRequest PullRequestOptions
+	// Keep holds glob patterns of the files the robot never creates, updates or deletes.
+	// A pattern matches either the repository path or the name of a file.
+	Keep []string
+	// Overrides holds the options overridden for single repositories.
+	// The keys are repository names or full names (owner/name).
+	Overrides map[string]RepoOverride
+	// Values holds custom values the templates can refer to as [[ .Values.name ]].
+	Values map[string]string
+	// LatestGoVersion is the latest stable Go version, e.g. 1.22.1. The version matrix in the templates
+	// ends with the minor version of it.
+	LatestGoVersion string
 }
 
-func NewUpdateWorkflowJob(user string, toMerge bool) (*updateWorkflowFilesJob, error) {
-	filesToUpdate, err := loadTemplates()
+func NewUpdateWorkflowJob(options UpdateWorkflowOptions) (*updateWorkflowFilesJob, error) {
+	templateSets, err := loadTemplateSets(options.Templates)
 	if err != nil {
-		return nil, fmt.Errorf("Error loading templates: %v\n", err)
+		return nil, err
+	}
+
+	return newUpdateJob(options, templateSets, branchPrefix)
+}
+
+// newUpdateJob validates the options of the pull requests and creates the job applying the template sets,
+// opening pull requests from branches starting with the prefix. Without template sets, the job commits
+// only the changes of its changers.
+func newUpdateJob(options UpdateWorkflowOptions, templateSets []templateSet, prefix string) (*updateWorkflowFilesJob, error) {
+	if options.MergeMethod == "" {
+		options.MergeMethod = DefaultMergeMethod
+	}
+	if err := validateMergeMethod(options.MergeMethod); err != nil {
+		return nil, err
 	}
 
-	if len(filesToUpdate) == 0 {
-		return nil, errors.New("no templates found")
+	if options.PullRequest.Title == "" {
+		options.PullRequest.Title = DefaultPRTitle
+	}
+	if options.PullRequest.Body == "" {
+		options.PullRequest.Body = DefaultPRBody
+	}
+	prTemplates, err := parseTemplates(map[string][]byte{
+		prTitleTemplate: []byte(options.PullRequest.Title),
+		prBodyTemplate:  []byte(options.PullRequest.Body),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid pull request title or body: %w", err)
+	}
+	if options.PullRequest.Draft && options.Merge {
+		return nil, fmt.Errorf("draft pull requests cannot be merged")
+	}
+	if (options.AutoMerge || options.WaitForChecks) && !options.Merge {
+		return nil, fmt.Errorf("auto-merge and waiting for the checks require merging")
+	}
+	if options.AutoMerge && options.WaitForChecks {
+		return nil, fmt.Errorf("auto-merge cannot be combined with waiting for the checks")
+	}
+	if options.ChecksTimeout <= 0 {
+		options.ChecksTimeout = DefaultChecksTimeout
+	}
+	if options.ChecksInterval <= 0 {
+		options.ChecksInterval = DefaultChecksInterval
 	}
 
-	prBranchName := branchPrefix + time.Now().Format(branchSafeTimeFormat)
+	prBranchName := prefix + time.Now().Format(branchSafeTimeFormat)
 
 	return &updateWorkflowFilesJob{
-		user:          user,
-		filesToUpdate: filesToUpdate,
-		PRBranchName:  prBranchName,
-		baseBranch:    "main",
-		toMerge:       toMerge,
+		templateSets:    templateSets,
+		PRBranchName:    prBranchName,
+		prBranchPrefix:  prefix,
+		toMerge:         options.Merge,
+		dryRun:          options.DryRun,
+		mergeMethod:     options.MergeMethod,
+		autoMerge:       options.AutoMerge,
+		waitForChecks:   options.WaitForChecks,
+		checksTimeout:   options.ChecksTimeout,
+		checksInterval:  options.ChecksInterval,
+		pullRequest:     options.PullRequest,
+		prTemplates:     prTemplates,
+		overrides:       options.Overrides,
+		keep:            options.Keep,
+		values:          options.Values,
+		latestGoVersion: options.LatestGoVersion,
 	}, nil
 }
 
-func (j *updateWorkflowFilesJob) UpdateWorkflow(ctx context.Context, repo *github.Repository) error {
-	printer := pretty.NewScopePrinter("---")
-	var result resultAction
+// Run updates the files of the repository managed by the robot through a pull request.
+func (j *updateWorkflowFilesJob) Run(ctx context.Context, repo RepoContext) (RepoResult, error) {
+	override := repoOverride(j.overrides, repo)
+	baseBranch := override.BaseBranch
+	if baseBranch == "" {
+		baseBranch = repo.DefaultBranch
+	}
 
-	// Get the current contents of .github/workflows
-	_, contents, _, err := client.Repositories.GetContents(ctx, j.user, repo.GetName(), ".github/workflows", &github.RepositoryContentGetOptions{})
-	if err != nil {
-		var errorResponse *github.ErrorResponse
-		if errors.As(err, &errorResponse) && errorResponse.Response.StatusCode == 404 {
-			printer.Skipped("No .github/workflows directory found.")
-			err = nil
-		} else {
-			err = fmt.Errorf("Error getting contents: %v\n", err)
+	// the job changing only the files of other jobs has no templates
+	var set *templateSet
+	if len(j.templateSets) != 0 {
+		selected, err := selectTemplateSet(j.templateSets, override.TemplateSet, repo)
+		if err != nil {
+			return RepoResult{}, err
 		}
+		if selected.Name != DefaultTemplateSet {
+			setPrinter := repo.Printer.WithPrefix("-")
+			setPrinter.Info("Template set '%s'", selected.Name)
+		}
+		set = &selected
+	}
+
+	u := &workflowUpdate{
+		updateWorkflowFilesJob: j,
+		repoContext:            repo,
+		owner:                  repo.Owner,
+		repo:                   repo.Name,
+		baseBranch:             baseBranch,
+		branch:                 j.PRBranchName,
+		override:               override,
+		mod:                    repo.Mod,
+		set:                    set,
+		printer:                repo.Printer,
+	}
+
+	err := u.update(ctx)
+	return u.result, err
+}
+
+func (u *workflowUpdate) update(ctx context.Context) error {
+	printer := u.printer.WithPrefix("---")
+
+	baseRef, err := u.getBaseRef(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = u.renderTemplates(); err != nil {
+		return err
+	}
+
+	// the blob SHAs of the files at the base branch tell which rendered files differ without downloading them
+	files, modes, err := getTreeBlobs(ctx, u.owner, u.repo, baseRef.GetObject().GetSHA())
+	if err != nil {
 		return err
 	}
 
-	printer.Info("Found %d files in .github/workflows", len(contents))
+	managed, manifestSHA, err := getManifest(ctx, u.owner, u.repo, u.baseBranch)
+	if err != nil {
+		return err
+	}
 
-	var filesToCreate = make(map[string]struct{})
-	for filePath := range j.filesToUpdate {
-		filesToCreate[filePath] = struct{}{}
+	// the pull requests left open by the previous runs are updated or closed instead of piling up
+	// only the pull requests of the job itself, as the other jobs change other files
+	robotPRs, err := listRobotPullRequests(ctx, u.owner, u.repo, u.baseBranch, u.prBranchPrefix)
+	if err != nil {
+		return err
 	}
-	for _, content := range contents {
-		printer.Info("Processing file '%s'", content.GetName())
-		// Check if the current file is one of the files to update
-		if newContent, found := j.filesToUpdate[content.GetName()]; found {
-			delete(filesToCreate, content.GetName())
+	u.superseded = robotPRs
 
-			var updateResult resultAction
-			updateResult, err = j.createBranchAndDo(ctx, repo.GetName(), content.GetPath(), newContent, updateAction)
-			if err != nil {
-				return fmt.Errorf("unable to update '%s': %v", content.GetName(), err)
+	var result resultAction
+	var changes []fileChange
+	// managedAfter lists the files the robot manages once the changes are merged
+	managedAfter := managed
+	if u.set != nil {
+		changes, managedAfter, result = u.templateChanges(files, managed)
+	}
 
+	// the changes of the jobs sharing the pull request are committed along with the ones of the templates
+	base := baseState{branch: u.baseBranch, files: files, managed: managedAfter, rendered: u.filesToUpdate}
+	for _, changer := range u.changers {
+		printer.Info("Changes of '%s'", changer.Name())
+		changerChanges, err := changer.fileChanges(ctx, u.repoContext, base)
+		if err != nil {
+			return fmt.Errorf("%s: %w", changer.Name(), err)
+		}
+		for _, change := range changerChanges {
+			if managedAfter[change.path] || change.path == manifestPath || slices.ContainsFunc(changes, func(c fileChange) bool { return c.path == change.path }) {
+				return fmt.Errorf("'%s' is changed both by '%s' and by another job", change.path, changer.Name())
 			}
-			result.add(updateResult)
-		} else {
-			// Delete the file since it's not one of the files to keep
-			var deleteResult resultAction
-			deleteResult, err = j.createBranchAndDo(ctx, repo.GetName(), content.GetPath(), nil, deleteAction)
-			if err != nil {
-				return fmt.Errorf("unable to delete '%s': %v", content.GetName(), err)
-			}
+		}
+		changes = append(changes, changerChanges...)
+	}
 
-			result.add(deleteResult)
+	// the manifest is only written by the templates, the files of the other jobs are never deleted. It is written
+	// even without other changes, so that the files already in sync become managed.
+	content := managedAfter.format()
+	if u.set != nil && (manifestSHA != "" || len(managedAfter) != 0) && blobSHA(content) != manifestSHA {
+		change := fileChange{path: manifestPath, action: createAction, content: content}
+		if manifestSHA != "" {
+			change.action, change.sha = updateAction, manifestSHA
 		}
+		changes = append(changes, change)
 	}
 
-	for filePath, fileContent := range j.filesToUpdate {
-		if _, found := filesToCreate[filePath]; !found {
-			continue
+	if len(changes) != 0 {
+		for i := range changes {
+			changes[i].mode = modes[changes[i].path]
+		}
+		u.changes = changes
+		if len(robotPRs) != 0 {
+			u.openPR, u.superseded = robotPRs[0], robotPRs[1:]
+			u.branch = u.openPR.GetHead().GetRef()
 		}
 
-		var createResult resultAction
-		createResult, err = j.createBranchAndDo(ctx, repo.GetName(), ".github/workflows/"+filePath, fileContent, createAction)
+		var changesResult resultAction
+		// the old contents are needed for the diffs and for the summary in the pull request
+		if err = loadOldContents(ctx, u.owner, u.repo, changes); err != nil {
+			return err
+		}
+		u.title, u.body, err = renderPullRequest(u.prTemplates, newPullRequestData(u.data, changes))
 		if err != nil {
-			return fmt.Errorf("unable to create '%s': %v", filePath, err)
+			return err
+		}
+		if u.dryRun {
+			changesResult = u.planChanges(changes)
+		} else {
+			changesResult, err = u.createBranchAndDo(ctx, baseRef, changes)
 		}
+		result.add(changesResult)
+	}
+
+	return u.finalizePR(ctx, err, result)
+}
 
-		result.add(createResult)
+// templateChanges returns the changes of the files rendered from the templates and of the managed files whose
+// templates are removed, along with the files the robot manages once the changes are merged.
+func (u *workflowUpdate) templateChanges(files map[string]string, managed manifest) (changes []fileChange, newManifest manifest, result resultAction) {
+	printer := u.printer.WithPrefix("---")
+
+	// newManifest lists the files rendered from the templates, which the robot manages from now on
+	newManifest = make(manifest)
+	for _, filePath := range sortedKeys(u.filesToUpdate) {
+		printer.Info("Processing file '%s'", filePath)
+		filePrinter := u.printer.WithPrefix("-----")
+		content := u.filesToUpdate[filePath]
+		sha, exists := files[filePath]
+		switch {
+		case u.keeps(filePath):
+			filePrinter.Skipped("Kept as it matches the keep list.")
+			result.add(resultSkipped)
+			continue
+		case !exists:
+			changes = append(changes, fileChange{path: filePath, action: createAction, content: content})
+		case sha == blobSHA(content):
+			filePrinter.Skipped("Content is the same.")
+			result.add(resultSkipped)
+		default:
+			changes = append(changes, fileChange{path: filePath, action: updateAction, content: content, sha: sha})
+		}
+		newManifest[filePath] = true
 	}
 
-	if err = j.finalizePR(ctx, err, result, repo); err != nil {
-		return err
+	// the files created by the robot from templates that no longer exist are deleted
+	for _, filePath := range sortedKeys(managed) {
+		sha, exists := files[filePath]
+		if _, rendered := u.filesToUpdate[filePath]; rendered || !exists || u.keeps(filePath) {
+			continue
+		}
+		changes = append(changes, fileChange{path: filePath, action: deleteAction, sha: sha})
 	}
 
-	return nil
+	return changes, newManifest, result
 }
 
-func (j *updateWorkflowFilesJob) finalizePR(ctx context.Context, err error, result resultAction, repo *github.Repository) error {
-	printer := pretty.NewScopePrinter("-")
+func (u *workflowUpdate) finalizePR(ctx context.Context, err error, result resultAction) error {
+	printer := u.printer.WithPrefix("-")
 	switch {
-	case err != nil || (j.branchCreated && !result.Changed()):
-		_, delErr := client.Git.DeleteRef(ctx, j.user, repo.GetName(), "refs/heads/"+j.PRBranchName)
-		if delErr != nil {
-			if err != nil {
-				err = fmt.Errorf("error deleting branch '%s': %w: %s", j.PRBranchName, delErr, err)
-			} else {
-				err = fmt.Errorf("error deleting branch '%s': %v", j.PRBranchName, delErr)
-			}
-		} else {
-			printer.Info("No updates made. Branch '%s' deleted.", j.PRBranchName)
+	case err != nil:
+		// nothing to clean up: a new branch is created last, and openOrUpdatePR deletes it if no pull request comes from it
+	case !result.Changed():
+		printer.Info("No updates needed.")
+		return u.closeSuperseded(ctx, nil)
+	case u.dryRun:
+		verb := "created"
+		if u.openPR != nil {
+			verb = fmt.Sprintf("#%d updated", u.openPR.GetNumber())
 		}
-	case result.Changed():
-		pr := &github.NewPullRequest{
-			Title:               github.String("Update Workflow YAML files"),
-			Head:                github.String(j.PRBranchName),
-			Base:                github.String(j.baseBranch),
-			Body:                github.String("This PR updates workflow files."),
-			MaintainerCanModify: github.Bool(true),
+		switch {
+		case u.autoMerge:
+			printer.Info("Dry run: a pull request '%s' to '%s' would be %s with auto-merge enabled", u.title, u.baseBranch, verb)
+		case u.waitForChecks:
+			printer.Info("Dry run: a pull request '%s' to '%s' would be %s and merged once the checks pass", u.title, u.baseBranch, verb)
+		case u.toMerge:
+			printer.Info("Dry run: a pull request '%s' to '%s' would be %s and merged", u.title, u.baseBranch, verb)
+		default:
+			printer.Info("Dry run: a pull request '%s' to '%s' would be %s", u.title, u.baseBranch, verb)
 		}
-		var prResponse *github.PullRequest
-		prResponse, _, err = client.PullRequests.Create(ctx, j.user, repo.GetName(), pr)
+		u.result.Add(outcomePRsPlanned, 1, u.owner+"/"+u.repo)
+		return u.closeSuperseded(ctx, u.openPR)
+	default:
+		var pr *github.PullRequest
+		pr, err = u.openOrUpdatePR(ctx)
 		if err != nil {
-			return fmt.Errorf("error creating pull request: %v", err)
+			return err
+		}
+		if err = u.closeSuperseded(ctx, pr); err != nil {
+			return err
 		}
 
-		// print the PR URL
-		printer.Info("Pull request created: %s", prResponse.GetHTMLURL())
-		j.prURLs = append(j.prURLs, prResponse.GetHTMLURL())
-		j.counter++
-
-		if j.toMerge {
-			_, _, err = client.PullRequests.Merge(ctx, j.user, repo.GetName(), prResponse.GetNumber(), "Merging PR", nil)
+		if !u.upToDate {
+			err = addPullRequestMetadata(ctx, u.owner, u.repo, pr.GetNumber(), pullRequestMetadata{
+				labels:        append(append([]string(nil), u.pullRequest.Labels...), u.override.Labels...),
+				assignees:     append(append([]string(nil), u.pullRequest.Assignees...), u.override.Assignees...),
+				reviewers:     append(append([]string(nil), u.pullRequest.Reviewers...), u.override.Reviewers...),
+				teamReviewers: u.pullRequest.TeamReviewers,
+				milestone:     u.pullRequest.Milestone,
+			})
 			if err != nil {
-				return fmt.Errorf("error merging pull request: %v", err)
+				return err
 			}
+		}
 
-			_, delErr := client.Git.DeleteRef(ctx, j.user, repo.GetName(), "refs/heads/"+j.PRBranchName)
-			if delErr != nil {
-				return fmt.Errorf("error deleting branch after pr was merged '%s': %w", j.PRBranchName, delErr)
-			}
+		if u.toMerge {
+			return u.merge(ctx, pr)
 		}
 	}
 
 	return err
 }
 
-// createBranchAndDo creates a branch, updates a file with a random string, and creates a PR.
-func (j *updateWorkflowFilesJob) createBranchAndDo(ctx context.Context, repo, filePath string, content []byte, action action) (result resultAction, err error) {
-	printer := pretty.NewScopePrinter("-----")
-
-	if action.RequiresContent() && len(content) == 0 {
-		err = fmt.Errorf("content cannot be empty upon updating a file")
-		return
-	}
-
-	// Step 1: Get content of the file
-	getContentOptions := new(github.RepositoryContentGetOptions)
-	if j.branchCreated {
-		getContentOptions.Ref = j.PRBranchName
-	}
+// openOrUpdatePR creates the pull request for the branch, or updates the title and the body of the open robot
+// pull request.
+func (u *workflowUpdate) openOrUpdatePR(ctx context.Context) (*github.PullRequest, error) {
+	printer := u.printer.WithPrefix("-")
 
-	var file *github.RepositoryContent
-	if action.RequiresSHA() {
-		file, _, _, err = client.Repositories.GetContents(ctx, j.user, repo, filePath, getContentOptions)
-		if err != nil {
-			err = fmt.Errorf("error retrieving file: %v", err)
-			return
+	if u.openPR != nil {
+		if u.upToDate {
+			printer.Info("Pull request is up to date: %s", u.openPR.GetHTMLURL())
+			u.result.Add(outcomePRsUpToDate, 1, u.openPR.GetHTMLURL())
+			return u.openPR, nil
 		}
-	}
 
-	var oldContent string
-	if action == updateAction {
-		oldContent, err = file.GetContent()
+		pr, _, err := client.PullRequests.Edit(ctx, u.owner, u.repo, u.openPR.GetNumber(), &github.PullRequest{
+			Title: github.String(u.title),
+			Body:  github.String(u.body),
+		})
 		if err != nil {
-			err = fmt.Errorf("error getting file content: %v", err)
-			return
+			return nil, fmt.Errorf("error updating pull request #%d: %w", u.openPR.GetNumber(), err)
 		}
 
-		if string(content) == oldContent {
-			printer.Skipped("Content is the same.")
-			result.add(resultSkipped)
-			return
-		}
+		printer.Info("Pull request updated: %s", pr.GetHTMLURL())
+		u.result.Add(outcomePRsUpdated, 1, pr.GetHTMLURL())
+		return pr, nil
 	}
 
-	if !j.branchCreated {
-		if err = j.createBranch(ctx, repo); err != nil {
-			return
+	pr, err := createPullRequest(ctx, u.owner, u.repo, &github.NewPullRequest{
+		Title:               github.String(u.title),
+		Head:                github.String(u.branch),
+		Base:                github.String(u.baseBranch),
+		Body:                github.String(u.body),
+		Draft:               github.Bool(u.pullRequest.Draft),
+		MaintainerCanModify: github.Bool(true),
+	})
+	if err != nil {
+		// do not leave the branch without a pull request behind
+		err = fmt.Errorf("error creating pull request: %v", err)
+		if delErr := deleteBranch(ctx, u.owner, u.repo, u.branch); delErr != nil {
+			err = fmt.Errorf("error deleting branch '%s': %w: %s", u.branch, delErr, err)
 		}
-
-		printer.OK("Branch '%s' created", j.PRBranchName)
-		j.branchCreated = true
-	}
-
-	// Step 4: Update Workflow file
-	switch action {
-	case updateAction:
-		var updateResult resultAction
-		updateResult, err = j.updateFile(ctx, repo, filePath, content, file, oldContent)
-		result.add(updateResult)
-	case deleteAction:
-		err = j.deleteFile(ctx, repo, filePath, file)
-		result.add(resultDeleted)
-	case createAction:
-		err = j.createFile(ctx, repo, filePath, content)
-		result.add(resultCreated)
-	default:
-		err = fmt.Errorf("unknown action: %v", action)
+		return nil, err
 	}
 
-	for _, r := range result.PrintAll() {
-		printer.OK(r)
-	}
-
-	return
-}
-
-func (j *updateWorkflowFilesJob) Counter() uint16 {
-	return j.counter
+	printer.Info("Pull request created: %s", pr.GetHTMLURL())
+	u.result.Add(outcomePRsCreated, 1, pr.GetHTMLURL())
+	return pr, nil
 }
 
-func (j *updateWorkflowFilesJob) User() string {
-	return j.user
-}
+// closeSuperseded closes the robot pull requests superseded by the pull request, or by the base branch
+// if pr is nil, and deletes their branches.
+func (u *workflowUpdate) closeSuperseded(ctx context.Context, pr *github.PullRequest) error {
+	printer := u.printer.WithPrefix("-")
 
-func (j *updateWorkflowFilesJob) addBadge(ctx context.Context, repo string, printer pretty.ScopePrinter) {
-	const badgeTemplate = `[![Tests](https://github.com/%s/%s/actions/workflows/test.yml/badge.svg?branch=%s)](https://github.com/%[1]s/%[2]s/actions/workflows/test.yml)`
-	badge := fmt.Sprintf(badgeTemplate, j.user, "luna", j.baseBranch)
-	// Step 5: Read README.md
-	readmeFile, _, _, err := client.Repositories.GetContents(ctx, j.user, repo, "README.md", &github.RepositoryContentGetOptions{Ref: j.baseBranch})
-	if err != nil {
-		printer.Error("error getting README.md: %v", err)
-	}
-
-	// Step 6: Update README.md
-	var readmeContent string
-	readmeContent, err = readmeFile.GetContent()
-	if err != nil {
-		printer.Error("error getting README.md content: %v", err)
+	comment := fmt.Sprintf("The files are up to date on '%s'.", u.baseBranch)
+	if pr != nil {
+		comment = fmt.Sprintf("Superseded by #%d.", pr.GetNumber())
 	}
 
-	readmeContent = strings.Replace(readmeContent, badge+"\n", "", -1)
-	badge = fmt.Sprintf(badgeTemplate, j.user, repo, j.baseBranch)
-	readmeContent = badge + "\n" + readmeContent
+	for _, superseded := range u.superseded {
+		if u.dryRun {
+			printer.Info("Dry run: pull request #%d would be closed: %s", superseded.GetNumber(), comment)
+			continue
+		}
 
-	updateResult, err := j.updateFile(ctx, repo, "README.md", []byte(readmeContent), readmeFile, readmeContent)
-	if err != nil {
-		printer.Error("error updating README.md: %v", err)
+		if err := closePullRequest(ctx, u.owner, u.repo, superseded, comment); err != nil {
+			return err
+		}
+		printer.OK("Pull request #%d closed: %s", superseded.GetNumber(), comment)
+		u.result.Add(outcomePRsClosed, 1, superseded.GetHTMLURL())
 	}
 
-	if updateResult.Updated() {
-		printer.OK("Added badge to README.md")
-	}
+	return nil
 }
 
-func (j *updateWorkflowFilesJob) createBranch(ctx context.Context, repo string) error {
-	// Step 2: Get the latest commit SHA of the base branch
-	baseRef, _, err := client.Git.GetRef(ctx, j.user, repo, "refs/heads/"+j.baseBranch)
-	if err != nil {
-		var errorResponse *github.ErrorResponse
-		if errors.As(err, &errorResponse) && errorResponse.Response.StatusCode == 404 && j.baseBranch != "master" {
-			j.baseBranch = "master"
-			baseRef, _, err = client.Git.GetRef(ctx, j.user, repo, "refs/heads/"+j.baseBranch)
-		}
+// merge merges the pull request, once its checks pass if requested, or enables its auto-merge.
+func (u *workflowUpdate) merge(ctx context.Context, pr *github.PullRequest) error {
+	printer := u.printer.WithPrefix("-")
 
-		if err != nil {
-			return fmt.Errorf("error getting base branch ref: %w", err)
+	if u.autoMerge {
+		if err := enableAutoMerge(ctx, pr, u.mergeMethod); err != nil {
+			return err
 		}
+		printer.OK("Auto-merge enabled")
+		u.result.Add(outcomePRsAutoMerged, 1, pr.GetHTMLURL())
+		return nil
 	}
 
-	// Step 3: Create a new branch from the latest commit of the base branch
-	newRef := &github.Reference{
-		Ref:    github.String("refs/heads/" + j.PRBranchName),
-		Object: &github.GitObject{SHA: baseRef.Object.SHA},
+	if u.waitForChecks {
+		printer.Info("Waiting for the checks of the pull request...")
+		if err := waitForChecks(ctx, u.owner, u.repo, u.headSHA, u.checksTimeout, u.checksInterval); err != nil {
+			// the pull request is left open for a human to look into
+			return fmt.Errorf("pull request not merged: %w", err)
+		}
 	}
-	_, _, err = client.Git.CreateRef(ctx, j.user, repo, newRef)
+
+	// the merge is rejected if the branch moved away from the commit checked above
+	err := mergePullRequest(ctx, u.owner, u.repo, pr.GetNumber(), "Merging PR", &github.PullRequestOptions{
+		MergeMethod: u.mergeMethod,
+		SHA:         u.headSHA,
+	})
 	if err != nil {
-		return fmt.Errorf("error creating new branch: %w", err)
+		return fmt.Errorf("error merging pull request: %v", err)
+	}
+	printer.OK("Pull request merged")
+	u.result.Add(outcomePRsMerged, 1, pr.GetHTMLURL())
+
+	delErr := deleteBranch(ctx, u.owner, u.repo, u.branch)
+	if delErr != nil {
+		return fmt.Errorf("error deleting branch after pr was merged '%s': %w", u.branch, delErr)
 	}
 
 	return nil
 }
 
-func (j *updateWorkflowFilesJob) updateFile(ctx context.Context, repo string, filePath string, content []byte, file *github.RepositoryContent, oldContent string) (result resultAction, err error) {
-	updateOpts := &github.RepositoryContentFileOptions{
-		Message: github.String(fmt.Sprintf("Update %s", filePath)),
-		Content: content,
-		Branch:  github.String(j.PRBranchName),
-		SHA:     file.SHA,
-	}
+// fileOutcomes maps the changes of the files to the outcomes of the job.
+var fileOutcomes = map[action]string{
+	createAction: outcomeFilesCreated,
+	updateAction: outcomeFilesUpdated,
+	deleteAction: outcomeFilesDeleted,
+}
 
-	_, _, err = client.Repositories.UpdateFile(ctx, j.user, repo, filePath, updateOpts)
-	if err != nil {
-		err = fmt.Errorf("error updating file: %v", err)
-		return
-	}
+// createBranchAndDo commits all the changes at once to a new branch created from the base branch.
+// The branch of the open robot pull request is forced onto the commit instead, unless it already holds the changes.
+func (u *workflowUpdate) createBranchAndDo(ctx context.Context, baseRef *github.Reference, changes []fileChange) (result resultAction, err error) {
+	printer := u.printer.WithPrefix("-----")
 
-	// Verify the file was Updated
-	// Retrieve the file again to check the new content
-	var updatedFileContent *github.RepositoryContent
-	updatedFileContent, _, _, err = client.Repositories.GetContents(ctx, j.user, repo, filePath, &github.RepositoryContentGetOptions{Ref: j.PRBranchName})
+	commit, err := commitChanges(ctx, u.owner, u.repo, baseRef.GetObject().GetSHA(), changes)
 	if err != nil {
-		err = fmt.Errorf("error retrieving Updated file: %v", err)
 		return
 	}
 
-	// Decode the content from base64
-	var decodedContent string
-	decodedContent, err = updatedFileContent.GetContent()
-	if err != nil {
-		err = fmt.Errorf("error decoding Updated file content: %v", err)
-		return
+	if u.openPR == nil {
+		// Create a new branch pointing to the commit
+		if err = createRef(ctx, u.owner, u.repo, u.branch, commit.GetSHA()); err != nil {
+			err = fmt.Errorf("error creating new branch: %w", err)
+			return
+		}
+		printer.OK("Branch '%s' created", u.branch)
+		u.headSHA = commit.GetSHA()
+	} else {
+		if u.upToDate, err = sameCommit(ctx, u.owner, u.repo, u.openPR.GetHead().GetSHA(), commit); err != nil {
+			return
+		}
+		if u.upToDate {
+			printer.Info("Branch '%s' already holds the changes", u.branch)
+			u.headSHA = u.openPR.GetHead().GetSHA()
+		} else {
+			// the branch is rebuilt on top of the base branch, dropping the commits of the previous runs
+			if err = updateRef(ctx, u.owner, u.repo, u.branch, commit.GetSHA()); err != nil {
+				err = fmt.Errorf("error updating branch '%s': %w", u.branch, err)
+				return
+			}
+			printer.OK("Branch '%s' updated", u.branch)
+			u.headSHA = commit.GetSHA()
+		}
 	}
 
-	// Check if the Updated content matches the expected content
-	if decodedContent != oldContent {
-		result.add(resultUpdated)
+	for _, change := range changes {
+		result.add(change.result())
+		printer.OK("%s '%s'", change.result(), change.path)
+		if !u.upToDate {
+			u.result.Add(fileOutcomes[change.action], 1)
+		}
 	}
 
 	return
 }
 
-func (j *updateWorkflowFilesJob) deleteFile(ctx context.Context, repo string, filePath string, file *github.RepositoryContent) error {
-	opts := &github.RepositoryContentFileOptions{
-		Message: github.String(fmt.Sprintf("Delete %s", filePath)),
-		SHA:     file.SHA,
-		Branch:  github.String(j.PRBranchName),
-	}
+// planChanges prints the changes createBranchAndDo would make and returns their result.
+func (u *workflowUpdate) planChanges(changes []fileChange) (result resultAction) {
+	printer := u.printer.WithPrefix("-----")
+
+	for _, change := range changes {
+		oldName, newName := "a/"+change.path, "b/"+change.path
+		switch change.action {
+		case deleteAction:
+			newName = "/dev/null"
+		case createAction:
+			oldName = "/dev/null"
+		}
 
-	_, _, err := client.Repositories.DeleteFile(ctx, j.user, repo, filePath, opts)
-	if err != nil {
-		return fmt.Errorf("error deleting file: %v", err)
+		printer.Info("'%s' would be %s", change.path, strings.ToLower(change.result().String()))
+		printer.Diff(diff.Unified(oldName, newName, string(change.oldContent), string(change.content)))
+		result.add(change.result())
 	}
 
-	return nil
+	return
 }
 
-func (j *updateWorkflowFilesJob) createFile(ctx context.Context, repo string, filePath string, content []byte) error {
-	opts := &github.RepositoryContentFileOptions{
-		Message: github.String(fmt.Sprintf("Create %s", filePath)),
-		Content: content,
-		Branch:  github.String(j.PRBranchName),
+// keeps reports whether the file is in the keep list of the job or of the repository.
+func (u *workflowUpdate) keeps(filePath string) bool {
+	return keeps(u.keep, filePath) || keeps(u.override.Keep, filePath)
+}
+
+// renderTemplates renders the templates with the data of the repository.
+func (u *workflowUpdate) renderTemplates() error {
+	u.data = newTemplateData(u.owner, u.repo, u.baseBranch, u.mod, u.latestGoVersion, mergeValues(u.values, u.override.Values))
+
+	if u.set == nil {
+		return nil
 	}
 
-	_, _, err := client.Repositories.CreateFile(ctx, j.user, repo, filePath, opts)
+	var err error
+	u.filesToUpdate, err = u.set.render(u.data)
+	return err
+}
+
+// getBaseRef returns the reference of the base branch.
+func (u *workflowUpdate) getBaseRef(ctx context.Context) (*github.Reference, error) {
+	baseRef, _, err := client.Git.GetRef(ctx, u.owner, u.repo, "refs/heads/"+u.baseBranch)
 	if err != nil {
-		return fmt.Errorf("error creating file: %v", err)
+		return nil, fmt.Errorf("error getting base branch '%s' ref: %w", u.baseBranch, err)
 	}
 
-	return nil
+	return baseRef, nil
 }
@@ -2,30 +2,46 @@ package pretty
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/kaatinga/robot/internal/color"
 )
 
-type ScopePrinter string
+type ScopePrinter struct {
+	prefix string
+	out    io.Writer
+}
 
 func NewScopePrinter(prefix string) ScopePrinter {
-	return ScopePrinter(prefix)
+	return NewScopePrinterTo(os.Stdout, prefix)
+}
+
+// NewScopePrinterTo returns a printer writing to out instead of the standard output.
+func NewScopePrinterTo(out io.Writer, prefix string) ScopePrinter {
+	return ScopePrinter{prefix: prefix, out: out}
+}
+
+// WithPrefix returns a printer writing to the same output with another prefix.
+func (s ScopePrinter) WithPrefix(prefix string) ScopePrinter {
+	return ScopePrinter{prefix: prefix, out: s.out}
 }
 
 func (s *ScopePrinter) AddPrefix(prefix string) {
-	*s = *s + ScopePrinter(prefix)
+	s.prefix += prefix
 }
 
 func (s ScopePrinter) printPrefix() {
-	fmt.Printf("%s%s%s", color.FaintItalic, s, color.Reset)
-	if len(s) > 0 {
-		fmt.Print(" ")
+	fmt.Fprintf(s.out, "%s%s%s", color.FaintItalic, s.prefix, color.Reset)
+	if len(s.prefix) > 0 {
+		fmt.Fprint(s.out, " ")
 	}
 }
 
 func (s *ScopePrinter) printMessage(message, status, statusColor string, arguments ...any) {
 	s.printPrefix()
-	fmt.Printf("[%s%s%s] %s\n", statusColor, status, color.Reset, fmt.Sprintf(message, arguments...))
+	fmt.Fprintf(s.out, "[%s%s%s] %s\n", statusColor, status, color.Reset, fmt.Sprintf(message, arguments...))
 }
 
 func (s *ScopePrinter) OK(message string, arguments ...any) {
@@ -43,3 +59,23 @@ func (s *ScopePrinter) Info(message string, arguments ...any) {
 func (s *ScopePrinter) Error(message string, arguments ...any) {
 	s.printMessage(message, " Error   ", color.Red, arguments...)
 }
+
+// Diff prints a unified diff line by line, highlighting the added and removed lines.
+func (s *ScopePrinter) Diff(diff string) {
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		lineColor := color.Normal
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			lineColor = color.Bold
+		case strings.HasPrefix(line, "@@"):
+			lineColor = color.Cyan
+		case strings.HasPrefix(line, "+"):
+			lineColor = color.Green
+		case strings.HasPrefix(line, "-"):
+			lineColor = color.Red
+		}
+
+		s.printPrefix()
+		fmt.Fprintf(s.out, "%s%s%s\n", lineColor, line, color.Reset)
+	}
+}
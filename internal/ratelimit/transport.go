@@ -0,0 +1,232 @@
+// Package ratelimit provides an HTTP transport that keeps the GitHub API rate limits.
+package ratelimit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultReserve is the number of requests kept in reserve unless configured otherwise.
+	DefaultReserve = 300
+	// defaultMaxRetries is the number of retries of a rate-limited request if Transport.MaxRetries is zero.
+	defaultMaxRetries = 3
+	// secondaryLimitWait is the time to wait after hitting a secondary rate limit without the Retry-After header,
+	// as recommended by the GitHub documentation.
+	secondaryLimitWait = time.Minute
+	// maxReserveShare caps the reserve of a resource at this share of its limit, so that the small limits
+	// such as the 30 search requests per minute are not kept in reserve entirely.
+	maxReserveShare = 10
+)
+
+// Transport is an http.RoundTripper that tracks the primary and secondary GitHub API rate limits.
+// Before a request it waits for the limit reset if fewer requests than the reserve remain,
+// and it retries requests rejected because of a rate limit once the limit allows it.
+type Transport struct {
+	// Base is the underlying transport. http.DefaultTransport is used if nil.
+	Base http.RoundTripper
+	// Reserve is the number of requests left untouched until the limit is reset. With zero, all of them are used.
+	// It is capped at a tenth of the limit of every resource.
+	Reserve int
+	// MaxRetries is the number of retries of a rate-limited request.
+	MaxRetries int
+	// Notify is called before every wait. It can be nil.
+	Notify func(wait time.Duration, reason string)
+
+	mu     sync.Mutex
+	limits map[string]limit
+	// blockedUntil is the time until which a secondary rate limit blocks all requests.
+	blockedUntil time.Time
+
+	// now and sleep are replaced in tests
+	now   func() time.Time
+	sleep func(req *http.Request, d time.Duration) error
+}
+
+// limit is the state of the primary rate limit of a resource, e.g. "core" or "search".
+type limit struct {
+	// limit is the number of requests allowed until the reset, zero if unknown
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := resourceOf(req)
+
+	for attempt := 0; ; attempt++ {
+		if wait, reason := t.waitBefore(resource); wait > 0 {
+			if err := t.wait(req, wait, reason); err != nil {
+				return nil, err
+			}
+		}
+
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("unable to retry rate-limited request %s %s: body cannot be rewound", req.Method, req.URL)
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := t.base().RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if !t.update(resource, resp) || attempt >= t.maxRetries() {
+			return resp, nil
+		}
+
+		// the response is dropped as the request is sent again once the limit allows it
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+}
+
+// waitBefore returns how long to wait before sending a request to the resource.
+func (t *Transport) waitBefore(resource string) (time.Duration, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock()
+	if t.blockedUntil.After(now) {
+		return t.blockedUntil.Sub(now), "secondary rate limit"
+	}
+
+	l, found := t.limits[resource]
+	if found && l.remaining <= t.reserve(l) && l.reset.After(now) {
+		return l.reset.Sub(now), fmt.Sprintf("%d %s requests left", l.remaining, resource)
+	}
+
+	return 0, ""
+}
+
+// update records the rate limit state given in the response. It reports whether the request was rejected
+// because of a rate limit.
+func (t *Transport) update(resource string, resp *http.Response) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	remaining, remainingErr := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	reset, resetErr := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if remainingErr == nil && resetErr == nil {
+		if headerResource := resp.Header.Get("X-RateLimit-Resource"); headerResource != "" {
+			resource = headerResource
+		}
+		if t.limits == nil {
+			t.limits = make(map[string]limit)
+		}
+		// the limit is only used to cap the reserve, it is unknown without the header
+		total, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+		t.limits[resource] = limit{limit: total, remaining: remaining, reset: time.Unix(reset, 0)}
+	}
+
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		t.blockedUntil = t.clock().Add(time.Duration(seconds) * time.Second)
+		return true
+	}
+
+	// the primary limit is exhausted, waitBefore delays the next attempt until the reset
+	if remainingErr == nil && remaining == 0 {
+		return true
+	}
+
+	if isSecondaryLimit(resp) {
+		t.blockedUntil = t.clock().Add(secondaryLimitWait)
+		return true
+	}
+
+	return false
+}
+
+// isSecondaryLimit reports whether the response body says a secondary rate limit was exceeded.
+// The body is restored so that it can still be read by the caller.
+func isSecondaryLimit(resp *http.Response) bool {
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	return bytes.Contains(bytes.ToLower(body), []byte("secondary rate limit"))
+}
+
+func (t *Transport) wait(req *http.Request, d time.Duration, reason string) error {
+	if t.Notify != nil {
+		t.Notify(d, reason)
+	}
+
+	if t.sleep != nil {
+		return t.sleep(req, d)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// resourceOf returns the rate limit resource the request is counted against.
+func resourceOf(req *http.Request) string {
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/search/"):
+		return "search"
+	case strings.HasPrefix(req.URL.Path, "/graphql"):
+		return "graphql"
+	default:
+		return "core"
+	}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+
+	return http.DefaultTransport
+}
+
+// reserve returns the number of requests of the limit kept in reserve.
+func (t *Transport) reserve(l limit) int {
+	if l.limit > 0 {
+		return min(t.Reserve, l.limit/maxReserveShare)
+	}
+
+	return t.Reserve
+}
+
+func (t *Transport) maxRetries() int {
+	if t.MaxRetries != 0 {
+		return t.MaxRetries
+	}
+
+	return defaultMaxRetries
+}
+
+func (t *Transport) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+
+	return time.Now()
+}
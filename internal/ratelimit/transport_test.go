@@ -0,0 +1,232 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	reset := now.Add(10 * time.Minute)
+
+	tests := []struct {
+		name string
+		// responses are returned one by one by the server
+		responses []func(w http.ResponseWriter)
+		wantWaits []time.Duration
+		wantCalls int
+		wantCode  int
+	}{
+		{
+			name: "plenty of requests left",
+			responses: []func(w http.ResponseWriter){
+				limitResponse(http.StatusOK, 1000, reset),
+				limitResponse(http.StatusOK, 999, reset),
+			},
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+		},
+		{
+			name: "reserve reached",
+			responses: []func(w http.ResponseWriter){
+				limitResponse(http.StatusOK, 10, reset),
+				limitResponse(http.StatusOK, 5000, reset.Add(time.Hour)),
+			},
+			wantWaits: []time.Duration{10 * time.Minute},
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+		},
+		{
+			name: "primary limit exhausted",
+			responses: []func(w http.ResponseWriter){
+				limitResponse(http.StatusForbidden, 0, reset),
+				limitResponse(http.StatusOK, 5000, reset.Add(time.Hour)),
+			},
+			wantWaits: []time.Duration{10 * time.Minute},
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+		},
+		{
+			name: "retry after",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "30")
+					w.WriteHeader(http.StatusTooManyRequests)
+				},
+				limitResponse(http.StatusOK, 5000, reset),
+			},
+			wantWaits: []time.Duration{30 * time.Second},
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+		},
+		{
+			name: "secondary limit without retry after",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.WriteHeader(http.StatusForbidden)
+					_, _ = w.Write([]byte(`{"message": "You have exceeded a secondary rate limit."}`))
+				},
+				limitResponse(http.StatusOK, 5000, reset),
+			},
+			wantWaits: []time.Duration{time.Minute},
+			wantCalls: 2,
+			wantCode:  http.StatusOK,
+		},
+		{
+			name: "forbidden",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.WriteHeader(http.StatusForbidden)
+					_, _ = w.Write([]byte(`{"message": "Resource not accessible by integration"}`))
+				},
+			},
+			wantCalls: 1,
+			wantCode:  http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.responses[calls](w)
+				calls++
+			}))
+			defer server.Close()
+
+			var waits []time.Duration
+			transport := &Transport{
+				Reserve: 100,
+				now:     func() time.Time { return now },
+				sleep: func(_ *http.Request, d time.Duration) error {
+					waits = append(waits, d)
+					now = now.Add(d)
+					return nil
+				},
+			}
+			defer func() { now = time.Unix(1_700_000_000, 0) }()
+
+			var resp *http.Response
+			for calls < len(tt.responses) {
+				req, err := http.NewRequest(http.MethodPost, server.URL+"/repos", strings.NewReader("{}"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp, err = transport.RoundTrip(req); err != nil {
+					t.Fatalf("RoundTrip() error = %v", err)
+				}
+				_ = resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					break
+				}
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("status code = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if len(waits) != len(tt.wantWaits) {
+				t.Fatalf("waits = %v, want %v", waits, tt.wantWaits)
+			}
+			for i := range waits {
+				if waits[i] != tt.wantWaits[i] {
+					t.Errorf("waits[%d] = %v, want %v", i, waits[i], tt.wantWaits[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTransport_noReserve(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	reset := now.Add(10 * time.Minute)
+	remaining := []int{1, 0, 5000}
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limitResponse(http.StatusOK, remaining[calls], reset)(w)
+		calls++
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	transport := &Transport{
+		now: func() time.Time { return now },
+		sleep: func(_ *http.Request, d time.Duration) error {
+			waits = append(waits, d)
+			return nil
+		},
+	}
+
+	// the last request left is used, and only the exhausted limit is waited for
+	for range remaining {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/repos", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip() error = %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	if len(waits) != 1 || waits[0] != 10*time.Minute {
+		t.Errorf("waits = %v, want [10m]", waits)
+	}
+}
+
+func TestTransport_smallLimit(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	reset := now.Add(time.Minute)
+	remaining := []int{29, 10, 3, 30}
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "30")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining[calls]))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.Header().Set("X-RateLimit-Resource", "search")
+		calls++
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	transport := &Transport{
+		Reserve: DefaultReserve,
+		now:     func() time.Time { return now },
+		sleep: func(_ *http.Request, d time.Duration) error {
+			waits = append(waits, d)
+			return nil
+		},
+	}
+
+	// the reserve of the 30 search requests is capped at 3, only the last request waits for the reset
+	for range remaining {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/search/repositories", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip() error = %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	if len(waits) != 1 || waits[0] != time.Minute {
+		t.Errorf("waits = %v, want [1m0s]", waits)
+	}
+}
+
+func limitResponse(code, remaining int, reset time.Time) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.Header().Set("X-RateLimit-Resource", "core")
+		w.WriteHeader(code)
+	}
+}
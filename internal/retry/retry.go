@@ -0,0 +1,70 @@
+// Package retry retries failed operations with exponential backoff and jitter.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// Policy configures the retries.
+type Policy struct {
+	// Attempts is the maximum number of attempts, including the first one.
+	Attempts int
+	// BaseDelay is the delay before the first retry. It doubles with every retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts.
+	MaxDelay time.Duration
+}
+
+// DefaultPolicy is used for the GitHub API calls.
+var DefaultPolicy = Policy{
+	Attempts:  4,
+	BaseDelay: 500 * time.Millisecond,
+	MaxDelay:  30 * time.Second,
+}
+
+// Delay returns a random delay before the given retry, from 1 for the first retry. The delay is taken
+// from the range between half and the whole of the exponential backoff so that concurrent clients spread out.
+func (p Policy) Delay(retry int) time.Duration {
+	backoff := p.BaseDelay
+	for i := 1; i < retry && backoff < p.MaxDelay; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, p.MaxDelay)
+	if backoff <= 0 {
+		return 0
+	}
+
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(backoff-half)+1))
+}
+
+// Do calls fn until it succeeds, returns an error retryable does not accept, or the attempts are exhausted.
+// The last error is returned.
+func Do(ctx context.Context, policy Policy, retryable func(error) bool, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || attempt >= policy.Attempts || !retryable(err) {
+			return err
+		}
+
+		if sleepErr := sleep(ctx, policy.Delay(attempt)); sleepErr != nil {
+			return errors.Join(err, sleepErr)
+		}
+	}
+}
+
+// sleep waits for the given time or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
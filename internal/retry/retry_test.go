@@ -0,0 +1,110 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPolicy_Delay(t *testing.T) {
+	policy := Policy{Attempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	tests := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{1, 500 * time.Millisecond, time.Second},
+		{2, time.Second, 2 * time.Second},
+		{3, 2 * time.Second, 4 * time.Second},
+		{4, 2500 * time.Millisecond, 5 * time.Second},
+		{9, 2500 * time.Millisecond, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if got := policy.Delay(tt.retry); got < tt.min || got > tt.max {
+				t.Fatalf("Delay(%d) = %v, want between %v and %v", tt.retry, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	errTransient := errors.New("transient")
+	errFatal := errors.New("fatal")
+	policy := Policy{Attempts: 3}
+	retryable := func(err error) bool { return errors.Is(err, errTransient) }
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}{
+		{"success", []error{nil}, nil, 1},
+		{"success after retries", []error{errTransient, errTransient, nil}, nil, 3},
+		{"attempts exhausted", []error{errTransient, errTransient, errTransient}, errTransient, 3},
+		{"not retryable", []error{errFatal}, errFatal, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			err := Do(context.Background(), policy, retryable, func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		codes     []int
+		wantCode  int
+		wantCalls int
+	}{
+		{"get retried", http.MethodGet, []int{http.StatusBadGateway, http.StatusOK}, http.StatusOK, 2},
+		{"get not found", http.MethodGet, []int{http.StatusNotFound}, http.StatusNotFound, 1},
+		{"get attempts exhausted", http.MethodGet, []int{502, 503, 504}, http.StatusGatewayTimeout, 3},
+		{"post not retried", http.MethodPost, []int{http.StatusBadGateway}, http.StatusBadGateway, 1},
+		{"put not retried", http.MethodPut, []int{http.StatusBadGateway}, http.StatusBadGateway, 1},
+		{"delete not retried", http.MethodDelete, []int{http.StatusBadGateway}, http.StatusBadGateway, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.codes[calls])
+				calls++
+			}))
+			defer server.Close()
+
+			transport := &Transport{Policy: Policy{Attempts: 3}}
+			req, err := http.NewRequest(tt.method, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("status code = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package retry
+
+import (
+	"io"
+	"net/http"
+)
+
+// Transport is an http.RoundTripper retrying safe requests (GET, HEAD and OPTIONS) that failed with a network error
+// or a transient server error (500, 502, 503 or 504). The other requests are sent once: even a PUT or a DELETE
+// meant to be idempotent answers differently once an earlier attempt succeeded, e.g. with 405 for a merged pull
+// request. Their retries have to be decided by the caller, see Do.
+type Transport struct {
+	// Base is the underlying transport. http.DefaultTransport is used if nil.
+	Base http.RoundTripper
+	// Policy configures the retries. DefaultPolicy is used if Attempts is zero.
+	Policy Policy
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	policy := t.Policy
+	if policy.Attempts == 0 {
+		policy = DefaultPolicy
+	}
+
+	if !isSafe(req.Method) || (req.Body != nil && req.GetBody == nil) {
+		return t.base().RoundTrip(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := t.base().RoundTrip(req)
+		if attempt >= policy.Attempts || req.Context().Err() != nil || (err == nil && !IsTransientStatus(resp.StatusCode)) {
+			return resp, err
+		}
+
+		if resp != nil {
+			// the response is dropped as the request is sent again
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		if err := sleep(req.Context(), policy.Delay(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+
+	return http.DefaultTransport
+}
+
+// IsTransientStatus reports whether the HTTP status code signals a failure that may disappear on retry.
+func IsTransientStatus(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func isSafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,22 @@
+// Package templates holds the default templates embedded in the robot.
+package templates
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// the all: prefix embeds the .github directory as well
+//
+//go:embed all:defaults
+var defaults embed.FS
+
+// Defaults returns the default templates. Their paths mirror the repository layout.
+func Defaults() fs.FS {
+	sub, err := fs.Sub(defaults, "defaults")
+	if err != nil {
+		panic(err)
+	}
+
+	return sub
+}
@@ -0,0 +1,339 @@
+package tool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is the configuration file loaded if it exists and no other file is given.
+const DefaultConfigFile = "robot.yaml"
+
+// Config describes a robot run. Every setting can be overridden with an environment variable or a flag.
+type Config struct {
+	// Owners holds the users and organizations whose repositories are processed.
+	Owners []string `yaml:"owners"`
+	// Filter selects the repositories to process.
+	Filter FilterConfig `yaml:"filter"`
+	// Workers is the number of repositories processed concurrently.
+	Workers *int `yaml:"workers"`
+	// ContinueOnError keeps processing the remaining repositories when a repository fails.
+	ContinueOnError bool `yaml:"continue_on_error"`
+	// RateLimitReserve is the number of API requests left untouched until the rate limit is reset.
+	RateLimitReserve *int `yaml:"rate_limit_reserve"`
+	// Templates is the directory with the templates replacing or extending the embedded ones in the default set.
+	Templates string `yaml:"templates"`
+	// NoEmbeddedTemplates leaves the embedded templates out of the default set.
+	NoEmbeddedTemplates bool `yaml:"no_embedded_templates"`
+	// TemplateSets are applied to the repositories they match instead of the default set.
+	TemplateSets []TemplateSetConfig `yaml:"template_sets"`
+	// Values holds custom values available in the templates.
+	Values map[string]string `yaml:"values"`
+	// Keep holds glob patterns of the files the robot never creates, updates or deletes.
+	Keep []string `yaml:"keep"`
+	// LatestGo is the latest stable Go version the version matrix ends with.
+	LatestGo string `yaml:"latest_go"`
+	// PullRequest describes the pull requests the robot opens.
+	PullRequest PullRequestConfig `yaml:"pull_request"`
+	// Merge describes how the pull requests are merged.
+	Merge MergeConfig `yaml:"merge"`
+	// Cleanup describes which robot branches cleanup-branches deletes.
+	Cleanup CleanupConfig `yaml:"cleanup"`
+	// Pipeline describes the jobs of the run command.
+	Pipeline PipelineConfig `yaml:"pipeline"`
+	// Repositories holds the settings overridden for single repositories given by name or owner/name.
+	Repositories map[string]RepositoryConfig `yaml:"repositories"`
+}
+
+// FilterConfig selects the repositories to process.
+type FilterConfig struct {
+	Include    []string `yaml:"include"`
+	Exclude    []string `yaml:"exclude"`
+	Match      string   `yaml:"match"`
+	Topics     []string `yaml:"topics"`
+	Visibility string   `yaml:"visibility"`
+	// PushedAfter is a date (2006-01-02) or a duration back from now (720h).
+	PushedAfter string   `yaml:"pushed_after"`
+	Languages   []string `yaml:"languages"`
+	Deny        []string `yaml:"deny"`
+}
+
+// TemplateSetConfig is a named set of templates. The first set matching a repository is applied to it.
+type TemplateSetConfig struct {
+	Name string `yaml:"name"`
+	// Dir is the directory the templates of the set are loaded from.
+	Dir string `yaml:"dir"`
+	// Extends is the name of the set whose templates are inherited, "default" for the default set.
+	Extends string `yaml:"extends"`
+	// Repos holds glob patterns of repository names and Topics holds topics matching the set.
+	Repos  []string `yaml:"repos"`
+	Topics []string `yaml:"topics"`
+}
+
+// PullRequestConfig describes the pull requests the robot opens.
+type PullRequestConfig struct {
+	// Title and Body are templates rendered with the changes made.
+	Title         string   `yaml:"title"`
+	Body          string   `yaml:"body"`
+	Labels        []string `yaml:"labels"`
+	Assignees     []string `yaml:"assignees"`
+	Reviewers     []string `yaml:"reviewers"`
+	TeamReviewers []string `yaml:"team_reviewers"`
+	// Milestone is the title of an open milestone.
+	Milestone string `yaml:"milestone"`
+	Draft     bool   `yaml:"draft"`
+}
+
+// MergeConfig describes how the pull requests are merged.
+type MergeConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Method is merge, squash or rebase.
+	Method string `yaml:"method"`
+	// Auto enables the native auto-merge instead of merging directly.
+	Auto bool `yaml:"auto"`
+	// WaitForChecks delays merging until the checks pass, polling them every ChecksInterval
+	// for at most ChecksTimeout.
+	WaitForChecks  bool          `yaml:"wait_for_checks"`
+	ChecksTimeout  time.Duration `yaml:"checks_timeout"`
+	ChecksInterval time.Duration `yaml:"checks_interval"`
+}
+
+// CleanupConfig describes which robot branches are deleted.
+type CleanupConfig struct {
+	// MinAge is the age a robot branch must reach to be deleted.
+	MinAge time.Duration `yaml:"min_age"`
+}
+
+// PipelineConfig describes the jobs run in every repository by the run command.
+type PipelineConfig struct {
+	// Jobs are the names of the jobs in the order they run in.
+	Jobs []string `yaml:"jobs"`
+	// SharePullRequest commits the changes of all the jobs changing files to a single pull request.
+	SharePullRequest bool `yaml:"share_pull_request"`
+}
+
+// RepositoryConfig holds the settings overridden for a single repository.
+type RepositoryConfig struct {
+	// Skip excludes the repository from every run.
+	Skip       bool   `yaml:"skip"`
+	BaseBranch string `yaml:"base_branch"`
+	// TemplateSet is the name of the template set applied to the repository whatever sets match it.
+	TemplateSet string            `yaml:"template_set"`
+	Values      map[string]string `yaml:"values"`
+	// Keep is added to the files kept in all the repositories.
+	Keep []string `yaml:"keep"`
+	// Labels, Assignees and Reviewers are added to the ones given for all the pull requests.
+	Labels    []string `yaml:"labels"`
+	Assignees []string `yaml:"assignees"`
+	Reviewers []string `yaml:"reviewers"`
+}
+
+// LoadConfig reads and validates the configuration file. Unknown settings are reported as errors.
+func LoadConfig(file string) (*Config, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading configuration: %w", err)
+	}
+
+	config := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing %s: %w", file, err)
+	}
+
+	if err = config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s:\n%w", file, err)
+	}
+
+	return config, nil
+}
+
+// Validate reports every invalid setting of the configuration.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(setting, format string, arguments ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", setting, fmt.Sprintf(format, arguments...)))
+	}
+
+	if c.Workers != nil && *c.Workers < 1 {
+		invalid("workers", "must be at least 1, got %d", *c.Workers)
+	}
+
+	if c.RateLimitReserve != nil && *c.RateLimitReserve < 0 {
+		invalid("rate_limit_reserve", "must not be negative, got %d", *c.RateLimitReserve)
+	}
+
+	for _, owner := range c.Owners {
+		if owner == "" || strings.Contains(owner, "/") {
+			invalid("owners", "'%s' is not a user or an organization", owner)
+		}
+	}
+
+	for _, pattern := range c.Filter.Include {
+		if _, err := path.Match(pattern, ""); err != nil {
+			invalid("filter.include", "invalid glob pattern '%s'", pattern)
+		}
+	}
+
+	for _, pattern := range c.Filter.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			invalid("filter.exclude", "invalid glob pattern '%s'", pattern)
+		}
+	}
+
+	if c.Filter.Match != "" {
+		if _, err := regexp.Compile(c.Filter.Match); err != nil {
+			invalid("filter.match", "%v", err)
+		}
+	}
+
+	if visibility := c.Filter.Visibility; visibility != "" && visibility != "public" && visibility != "private" {
+		invalid("filter.visibility", "must be public or private, got '%s'", visibility)
+	}
+
+	if c.Filter.PushedAfter != "" {
+		if _, err := ParseSince(c.Filter.PushedAfter); err != nil {
+			invalid("filter.pushed_after", "%v", err)
+		}
+	}
+
+	if method := c.Merge.Method; method != "" && method != "merge" && method != "squash" && method != "rebase" {
+		invalid("merge.method", "must be merge, squash or rebase, got '%s'", method)
+	}
+	if c.PullRequest.Draft && c.Merge.Enabled {
+		invalid("pull_request.draft", "draft pull requests cannot be merged")
+	}
+	if c.Merge.Auto && c.Merge.WaitForChecks {
+		invalid("merge.auto", "cannot be combined with wait_for_checks")
+	}
+	if c.Merge.ChecksTimeout < 0 {
+		invalid("merge.checks_timeout", "must not be negative")
+	}
+	if c.Merge.ChecksInterval < 0 {
+		invalid("merge.checks_interval", "must not be negative")
+	}
+	if c.Cleanup.MinAge < 0 {
+		invalid("cleanup.min_age", "must not be negative")
+	}
+
+	errs = append(errs, c.validateTemplateSets()...)
+
+	for _, pattern := range c.Keep {
+		if _, err := path.Match(pattern, ""); err != nil {
+			invalid("keep", "invalid glob pattern '%s'", pattern)
+		}
+	}
+
+	if _, found := c.Values[""]; found {
+		invalid("values", "a value without a name")
+	}
+
+	names := make([]string, 0, len(c.Repositories))
+	for name := range c.Repositories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if owner, repo, found := strings.Cut(name, "/"); owner == "" || (found && (repo == "" || strings.Contains(repo, "/"))) {
+			invalid("repositories", "'%s' is neither a name nor owner/name", name)
+		}
+		if set := c.Repositories[name].TemplateSet; set != "" && !c.hasTemplateSet(set) {
+			invalid("repositories."+name+".template_set", "unknown template set '%s'", set)
+		}
+		for _, pattern := range c.Repositories[name].Keep {
+			if _, err := path.Match(pattern, ""); err != nil {
+				invalid("repositories."+name+".keep", "invalid glob pattern '%s'", pattern)
+			}
+		}
+		if _, found := c.Repositories[name].Values[""]; found {
+			invalid("repositories."+name+".values", "a value without a name")
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// defaultTemplateSet is the name of the set loaded from Templates.
+const defaultTemplateSet = "default"
+
+// hasTemplateSet reports whether the set is the default one or defined in the configuration.
+func (c *Config) hasTemplateSet(name string) bool {
+	if name == defaultTemplateSet {
+		return true
+	}
+
+	for _, set := range c.TemplateSets {
+		if set.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateTemplateSets reports the invalid template sets and the inheritance cycles.
+func (c *Config) validateTemplateSets() []error {
+	var errs []error
+	extends := make(map[string]string, len(c.TemplateSets))
+	for i, set := range c.TemplateSets {
+		setting := fmt.Sprintf("template_sets[%d]", i)
+		switch _, duplicate := extends[set.Name]; {
+		case set.Name == "" || set.Name == defaultTemplateSet:
+			errs = append(errs, fmt.Errorf("%s.name: must not be empty or '%s'", setting, defaultTemplateSet))
+		case duplicate:
+			errs = append(errs, fmt.Errorf("%s.name: '%s' is defined more than once", setting, set.Name))
+		}
+		extends[set.Name] = set.Extends
+
+		if set.Dir == "" && set.Extends == "" {
+			errs = append(errs, fmt.Errorf("%s: either dir or extends must be given", setting))
+		}
+		if set.Extends != "" && !c.hasTemplateSet(set.Extends) {
+			errs = append(errs, fmt.Errorf("%s.extends: unknown template set '%s'", setting, set.Extends))
+		}
+		for _, pattern := range set.Repos {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("%s.repos: invalid glob pattern '%s'", setting, pattern))
+			}
+		}
+	}
+
+	for _, set := range c.TemplateSets {
+		// follow the parents until the chain ends, comes back to the set or loops among other sets
+		seen := make(map[string]bool)
+		for parent := extends[set.Name]; parent != "" && !seen[parent]; parent = extends[parent] {
+			if parent == set.Name {
+				errs = append(errs, fmt.Errorf("template_sets: '%s' inherits from itself", set.Name))
+				break
+			}
+			seen[parent] = true
+		}
+	}
+
+	return errs
+}
+
+// ParseSince parses a point in time given as a date (2006-01-02) or as a duration back from now (720h).
+func ParseSince(value string) (time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is neither a date nor a duration", value)
+	}
+
+	return time.Now().Add(-duration), nil
+}
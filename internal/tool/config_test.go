@@ -0,0 +1,157 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		// wantErr holds the parts of the expected error message; no error is expected if empty
+		wantErr []string
+	}{
+		{
+			name:    "empty",
+			content: "",
+		},
+		{
+			name: "valid",
+			content: `owners: [kaatinga, my-org]
+filter:
+  include: ['const-*']
+  match: '^[a-z-]+$'
+  visibility: public
+  pushed_after: 720h
+workers: 4
+pull_request:
+  title: Sync workflows
+  labels: [ci]
+merge:
+  enabled: true
+  method: squash
+  wait_for_checks: true
+  checks_timeout: 1h
+  checks_interval: 1m
+cleanup:
+  min_age: 168h
+pipeline:
+  jobs: [update-workflows, cleanup-branches]
+  share_pull_request: true
+template_sets:
+  - name: library
+    extends: default
+    dir: templates/library
+    topics: [library]
+  - name: service
+    extends: library
+    dir: templates/service
+    repos: ['*-service']
+repositories:
+  kaatinga/settings:
+    base_branch: develop
+    template_set: library
+    values:
+      runner: self-hosted
+  legacy:
+    skip: true
+`,
+		},
+		{
+			name:    "unknown setting",
+			content: "owner: kaatinga\n",
+			wantErr: []string{"line 1", "field owner not found"},
+		},
+		{
+			name: "invalid template sets",
+			content: `template_sets:
+  - name: default
+    dir: templates/default
+  - name: a
+    extends: b
+  - name: b
+    extends: a
+  - name: c
+    dir: templates/c
+    repos: ['[']
+  - name: c
+  - name: d
+    extends: unknown
+repositories:
+  robot:
+    template_set: e
+`,
+			wantErr: []string{
+				"template_sets[0].name: must not be empty or 'default'",
+				"template_sets[3].repos: invalid glob pattern '['",
+				"template_sets[4].name: 'c' is defined more than once",
+				"template_sets[4]: either dir or extends must be given",
+				"template_sets[5].extends: unknown template set 'unknown'",
+				"template_sets: 'a' inherits from itself",
+				"template_sets: 'b' inherits from itself",
+				"repositories.robot.template_set: unknown template set 'e'",
+			},
+		},
+		{
+			name: "invalid settings",
+			content: `workers: 0
+filter:
+  match: '('
+  visibility: internal
+  pushed_after: yesterday
+merge:
+  enabled: true
+  method: fast-forward
+  auto: true
+  wait_for_checks: true
+  checks_interval: -1s
+cleanup:
+  min_age: -1h
+pull_request:
+  draft: true
+repositories:
+  a/b/c: {}
+`,
+			wantErr: []string{
+				"workers: must be at least 1",
+				"filter.match:",
+				"filter.visibility: must be public or private",
+				"filter.pushed_after: 'yesterday' is neither a date nor a duration",
+				"merge.method: must be merge, squash or rebase",
+				"pull_request.draft: draft pull requests cannot be merged",
+				"merge.auto: cannot be combined with wait_for_checks",
+				"merge.checks_interval: must not be negative",
+				"cleanup.min_age: must not be negative",
+				"repositories: 'a/b/c' is neither a name nor owner/name",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), DefaultConfigFile)
+			if err := os.WriteFile(file, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadConfig(file)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("LoadConfig() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("LoadConfig() succeeded")
+			}
+			for _, part := range tt.wantErr {
+				if !strings.Contains(err.Error(), part) {
+					t.Errorf("LoadConfig() error = %v, want it to contain %q", err, part)
+				}
+			}
+		})
+	}
+}
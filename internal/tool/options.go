@@ -1,27 +1,86 @@
 package tool
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/kaatinga/robot/internal/ratelimit"
 	"github.com/kaatinga/settings"
 )
 
+// Options holds the settings of a robot run. The defaults are overridden by the configuration file,
+// which is overridden by the environment variables.
 type Options struct {
 	//GitHubAPIKey string `env:"GITHUB_API_KEY" required:"true"`
 	//OpenAIKey    string `env:"OPENAI_API_KEY" required:"true"`
 	GitHubToken string `env:"GITHUB_TOKEN" required:"true"`
+	// ConfigFile is the configuration file. DefaultConfigFile is loaded if it exists and no file is given.
+	ConfigFile string `env:"ROBOT_CONFIG"`
+	// RateLimitReserve is the number of API requests left untouched until the rate limit is reset.
+	RateLimitReserve int `env:"ROBOT_RATE_LIMIT_RESERVE" validate:"gte=0"`
+	// Workers is the number of repositories processed concurrently.
+	Workers int `env:"ROBOT_WORKERS" validate:"gte=1"`
 }
 
-var toolSettings = &Options{}
+var (
+	toolSettings = &Options{}
+	toolConfig   = &Config{}
+)
+
+// Init loads the configuration file and the environment variables. The configuration file given here
+// takes precedence over the one given in the environment.
+func Init(configFile string) error {
+	toolSettings.RateLimitReserve = ratelimit.DefaultReserve
+	toolSettings.Workers = 1
+
+	if configFile == "" {
+		configFile = os.Getenv("ROBOT_CONFIG")
+	}
+
+	config, err := loadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	toolConfig = config
+
+	if config.RateLimitReserve != nil {
+		toolSettings.RateLimitReserve = *config.RateLimitReserve
+	}
+	if config.Workers != nil {
+		toolSettings.Workers = *config.Workers
+	}
 
-func Init() error {
 	// Load environment variables
-	if err := settings.Load(toolSettings); err != nil {
+	if err = settings.Load(toolSettings); err != nil {
 		return fmt.Errorf("failed to load settings: %w", err)
 	}
+	toolSettings.ConfigFile = configFile
 
 	return nil
 }
 
+// loadConfig loads the configuration file, or DefaultConfigFile if no file is given.
+// An empty configuration is returned if no file is given and DefaultConfigFile does not exist.
+func loadConfig(file string) (*Config, error) {
+	if file != "" {
+		return LoadConfig(file)
+	}
+
+	config, err := LoadConfig(DefaultConfigFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+
+	return config, err
+}
+
 func GetOptions() *Options {
 	return toolSettings
 }
+
+// GetConfig returns the loaded configuration file. It is empty if no file was loaded.
+func GetConfig() *Config {
+	return toolConfig
+}
@@ -2,38 +2,78 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 
 	"github.com/kaatinga/robot/internal/job"
 	"github.com/kaatinga/robot/internal/pretty"
+	"github.com/kaatinga/robot/internal/ratelimit"
 	"github.com/kaatinga/robot/internal/tool"
 )
 
 func main() {
-	if err := tool.Init(); err != nil {
-		log.Fatal(err)
+	os.Exit(run(os.Args[1:]))
+}
+
+// run executes the subcommand given in args and returns the process exit code.
+func run(args []string) int {
+	printer := pretty.NewScopePrinter("")
+
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage()
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
 	}
 
-	job.Init()
+	cmd, cmdArgs, found := findCommand(args)
+	if !found {
+		printer.Error("Unknown command '%s'", args[0])
+		usage()
+		return 2
+	}
 
-	printer := pretty.NewScopePrinter("")
-	user := "kaatinga"
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: robot %s [flags]\n\n%s\n\nFlags:\n", cmd.name, cmd.description)
+		flags.PrintDefaults()
+	}
+	configFile := flags.String("config", "", "configuration file; overrides ROBOT_CONFIG (default robot.yaml if it exists)")
+	rateLimitReserve := flags.Int("rate-limit-reserve", ratelimit.DefaultReserve, "number of API requests left untouched until the rate limit is reset; overrides ROBOT_RATE_LIMIT_RESERVE")
+	runCmd := cmd.setup(flags)
+	if err := flags.Parse(cmdArgs); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
 
-	job1, err := job.NewUpdateWorkflowJob(user, true)
-	if err != nil {
-		printer.Error("Failure: %v", err)
-		os.Exit(1)
+	if err := tool.Init(*configFile); err != nil {
+		printer.Error("%v", err)
+		return 1
+	}
+
+	if isSet(flags, "rate-limit-reserve") {
+		tool.GetOptions().RateLimitReserve = *rateLimitReserve
 	}
-	err = job.FetchAllGoRepos(context.Background(), job1, job1.UpdateWorkflow)
-	if err != nil {
+
+	job.Init()
+
+	if err := runCmd(context.Background()); err != nil {
 		printer.Error("%v", err)
-		os.Exit(1)
+		return 1
 	}
 
-	// job2 := job.NewDeleteOldRobotBranchesJob(user)
-	// if err := job.FetchAllGoRepos(context.Background(), job2, job2.DeleteLeftRobotBranches); err != nil {
-	// 	printer.Error("%v", err)
-	// 	os.Exit(1)
-	// }
+	return 0
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: robot <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'robot <command> -h' to see the flags of a command.\n")
 }